@@ -0,0 +1,90 @@
+package auditor
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// archiveOutputs zips every file recorded in the manifest, i.e. only this
+// run's files, into audit_<timestamp>.zip and records the archive in the
+// manifest. The manifest itself is written afterwards and stays outside
+// the archive so later runs can still read it.
+func (run *auditRun) archiveOutputs() (string, error) {
+	run.manifest.mu.Lock()
+	files := append([]string{}, run.manifest.Files...)
+	run.manifest.mu.Unlock()
+
+	path := run.outputPath(fmt.Sprintf("audit_%s.zip", run.timestamp))
+	archive, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("error creating archive: %w", err)
+	}
+
+	zipWriter := zip.NewWriter(archive)
+	for _, file := range files {
+		if err := addToArchive(zipWriter, file); err != nil {
+			zipWriter.Close()
+			archive.Close()
+			os.Remove(path)
+			return "", err
+		}
+	}
+
+	// Both closes write data: the zip central directory and the file itself.
+	if err := zipWriter.Close(); err != nil {
+		archive.Close()
+		os.Remove(path)
+		return "", fmt.Errorf("error finalizing archive: %w", err)
+	}
+	if err := archive.Close(); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("error closing archive: %w", err)
+	}
+
+	run.manifest.Archive = path
+	return path, nil
+}
+
+func addToArchive(zipWriter *zip.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening %s for archiving: %w", path, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", path, err)
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return fmt.Errorf("error archiving %s: %w", path, err)
+	}
+	header.Name = filepath.Base(path)
+	header.Method = zip.Deflate
+
+	entry, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("error archiving %s: %w", path, err)
+	}
+	if _, err := io.Copy(entry, file); err != nil {
+		return fmt.Errorf("error archiving %s: %w", path, err)
+	}
+	return nil
+}
+
+// removeArchivedFiles deletes the loose copies of the archived files.
+func (run *auditRun) removeArchivedFiles() {
+	run.manifest.mu.Lock()
+	defer run.manifest.mu.Unlock()
+
+	for _, file := range run.manifest.Files {
+		if err := os.Remove(file); err != nil {
+			slog.Error("Error removing archived file", "path", file, "error", err)
+		}
+	}
+}
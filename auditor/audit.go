@@ -0,0 +1,2368 @@
+package auditor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/identity"
+	"github.com/oracle/oci-go-sdk/v65/resourcesearch"
+	"gopkg.in/ini.v1"
+)
+
+// ownerTagKey is the defined tag key that marks a resource's owner.
+const ownerTagKey = "CreatedBy"
+
+// config holds the options of one run, from the command-line flags or an
+// Auditor, and the checks loaded from them at startup. Every run has its
+// own, so runs in one process do not share state.
+type config struct {
+	createMissingTagsFile bool
+	createNoOwnerFile     bool
+	auditTagDefaults      bool
+	maxTotal              int
+	maxResources          int
+	tagCoverageReport     bool
+	normalizeTagKeys      bool
+	namespaceUsageReport  bool
+	typeInventoryReport   bool
+	tagRulesFile          string
+	sinceLastRun          bool
+	sinceDate             string
+	minimalFields         bool
+	byReason              bool
+	tenancyName           string
+	prefixTenancy         bool
+	settingsFile          string
+	transitionalStateList string
+	deltaReport           bool
+	debugOCID             string
+	archiveOutput         bool
+	archiveCleanup        bool
+	tagConflicts          bool
+	ownerEquivalents      string
+	costTagList           string
+	userAgentSuffix       string
+	resourceOCIDs         stringsFlag
+	combinedReport        bool
+	combinedBuffer        int
+	lookupConcurrency     int
+	tagsBoth              bool
+	labelBy               string
+	pagePrefetch          bool
+	checksums             bool
+	environmentTagName    string
+	postHook              string
+	graceDays             int
+	partitionByDate       bool
+	runDirFlag            bool
+	maxCellLength         int
+	splitByType           bool
+	adaptiveConcurrency   bool
+	ownerDefaultsCheck    bool
+	strictJSON            bool
+	tenanciesFile         string
+	homeRegionKey         string
+	skipHomeRegion        bool
+	maxConsecutiveEmpty   int
+	maxPages              int
+	searchQuery           string
+	profileName           string
+	weightTagName         string
+	weightList            string
+	compartmentCompliance bool
+	changesMarkdown       bool
+	outputFormat          string
+	sortBy                string
+	derivedColumnSpecs    repeatedFlag
+	requestRate           float64
+	maxRetries            int
+	authMode              string
+	configFile            string
+	requiredTagList       string
+	requiredTagPolicyFile string
+	timeout               time.Duration
+	flattenTagList        string
+	uploadBucket          string
+	uploadNamespace       string
+	uploadPrefix          string
+	uploadPartSize        int
+	dryRun                bool
+	gzipOutput            bool
+	regionConcurrency     int
+	resolveCompartments   bool
+	minAgeDays            int
+	writeMetrics          bool
+	dedupReportFlag       bool
+	logLevel              string
+	resourceTypeList      string
+	webhookURL            string
+	compartmentID         string
+	exceptionsOnly        bool
+	showNamespaces        bool
+	regionList            string
+	progressInterval      time.Duration
+	queryFile             string
+	compartmentExact      bool
+	// dataDir is the root of all output files, set with -output-dir.
+	dataDir              string
+	webhookAlways        bool
+	logFormat            string
+	metricsFile          string
+	includeUnknownAge    bool
+	minConcurrency       int
+	maxConcurrency       int
+	maxOpenTypeFiles     int
+	cellOverflow         bool
+	postHookConcurrency  int
+	checkRetired         bool
+	baselineFile         string
+	globalFromHomeOnly   bool
+	writeIndex           bool
+	minTags              int
+	ociLoggingID         string
+	ociLoggingViolations bool
+	objectPrefix         string
+	ownerFreeformKey     string
+	ownerValueRegex      string
+	ownerTagName         string
+	ownerPlaceholderList string
+
+	// tagRules are loaded from tagRulesFile at startup.
+	tagRules []tagRule
+	// transitionalStates is the upper-cased set from transitionalStateList.
+	transitionalStates map[string]bool
+	// ownerValuePattern is compiled from ownerValueRegex at startup.
+	ownerValuePattern *regexp.Regexp
+	// ownerTag is parsed from ownerTagName at startup; nil keeps matching
+	// CreatedBy in any namespace.
+	ownerTag *tagRef
+	// ownerPlaceholders is the lower-cased set from ownerPlaceholderList.
+	ownerPlaceholders map[string]bool
+	// ownerSources is parsed from ownerEquivalents at startup.
+	ownerSources []tagSource
+	// costTags is parsed from costTagList at startup.
+	costTags []tagRef
+	// environmentTag is parsed from environmentTagName at startup; nil when
+	// there is no environment dimension.
+	environmentTag *tagRef
+
+	// weightTag and weightValues are parsed from weightTagName and
+	// weightList at startup; weightTag is nil when -weight-tag is not set.
+	weightTag    *tagRef
+	weightValues map[string]float64
+
+	// requiredTags and flattenTags are parsed from requiredTagList and
+	// flattenTagList at startup.
+	requiredTags []tagRef
+	flattenTags  []tagRef
+
+	// requiredTagsByType is loaded from requiredTagPolicyFile, keyed by
+	// lowercased resource type; nil without -required-tags-policy.
+	requiredTagsByType map[string][]tagRef
+
+	// compartmentNames is loaded with -compartment-names before the scan;
+	// nil otherwise.
+	compartmentNames *compartmentNameCache
+
+	// derivedColumns are compiled from derivedColumnSpecs at startup.
+	derivedColumns []column
+	// retiredStatus is loaded at startup with -check-retired-namespaces; nil
+	// when the check is off or the namespaces could not be read.
+	retiredStatus *namespaceStatus
+	// ownerDefaults is loaded at startup with -owner-defaults; nil when the
+	// check is off or the tag defaults could not be read.
+	ownerDefaults *compartmentDefaults
+	// baseline is loaded from baselineFile at startup.
+	baseline *baselineCompliance
+
+	// flags is the flag set the options were registered on, read by
+	// flagSet.
+	flags *flag.FlagSet
+	// userHooks are the Auditor's Hooks, run after the built-in callbacks
+	// for every resource and page.
+	userHooks Hooks
+
+	// principal is created once per run with -auth instance or resource.
+	principalOnce     sync.Once
+	principalProvider common.ConfigurationProvider
+	principalErr      error
+}
+
+// registerFlags defines the command-line flags of the options on fs.
+func (cfg *config) registerFlags(fs *flag.FlagSet) {
+	cfg.flags = fs
+	fs.BoolVar(&cfg.createMissingTagsFile, "missing-tags", false, "Create a separate file for resources with missing defined tags")
+	fs.BoolVar(&cfg.createNoOwnerFile, "no-owner", false, "Create a separate file for resources with missing CreatedBy tag")
+	fs.IntVar(&cfg.maxTotal, "max-total", 0, "Stop the run after this many resources across all regions (0 means no limit)")
+	fs.IntVar(&cfg.maxResources, "max-resources", 0, "Stop each region after this many resources written to its report (0 means no limit)")
+	fs.BoolVar(&cfg.tagCoverageReport, "tag-coverage", false, "Create a tenancy-wide report of how many resources carry each tag key")
+	fs.BoolVar(&cfg.normalizeTagKeys, "normalize-tag-keys", false, "Lowercase tag keys when aggregating the tag coverage report")
+	fs.BoolVar(&cfg.namespaceUsageReport, "namespace-usage", false, "Create a tenancy-wide report of how many resources use each defined-tag namespace")
+	fs.BoolVar(&cfg.typeInventoryReport, "resource-type-inventory", false, "Create a report of resource counts per resource type, tenancy-wide and per region")
+	fs.StringVar(&cfg.tagRulesFile, "tag-rules", "", "JSON file of allowed values or patterns per Namespace.Key; violations go to a separate file")
+	fs.BoolVar(&cfg.sinceLastRun, "since-last-run", false, "Only report resources created since the last complete scan recorded in the output directory")
+	fs.StringVar(&cfg.sinceDate, "since", "", "Only report resources created after this time, as RFC3339 or YYYY-MM-DD (midnight UTC)")
+	fs.BoolVar(&cfg.minimalFields, "minimal-fields", false, "Only write the Profile, Resource Region, Resource Type, Identifier and Compartment ID columns")
+	fs.BoolVar(&cfg.byReason, "by-reason", false, "Create one file per non-compliance reason, listing the resources failing it")
+	fs.StringVar(&cfg.tenancyName, "tenancy-name", "", "Tenancy name used by -prefix-tenancy (defaults to the name returned by GetTenancy)")
+	fs.BoolVar(&cfg.prefixTenancy, "prefix-tenancy", false, "Prefix every output file name with the tenancy name")
+	fs.StringVar(&cfg.settingsFile, "settings", "", "JSON settings file with the search query and per-region query overrides")
+	fs.StringVar(&cfg.transitionalStateList, "transitional-states", "PROVISIONING,CREATING,UPDATING,DELETING,TERMINATING", "Comma-separated lifecycle states counted as in-flight and excluded from compliance checks")
+	fs.BoolVar(&cfg.deltaReport, "delta", false, "Create a file of resources that are new, changed or removed since the previous report in the output directory")
+	fs.StringVar(&cfg.debugOCID, "debug-ocid", "", "Dump the raw search result for this OCID to stderr as JSON")
+	fs.StringVar(&cfg.ownerFreeformKey, "owner-freeform-key", "", "Freeform tag key that also counts as an owner, e.g. owner")
+	fs.StringVar(&cfg.ownerTagName, "owner-tag", "", "Defined tag (Namespace.Key) that marks a resource's owner (default: CreatedBy in any namespace)")
+	fs.StringVar(&cfg.ownerPlaceholderList, "owner-placeholders", "", "Comma-separated owner values that count as no owner, e.g. unknown,n/a (case-insensitive)")
+	fs.StringVar(&cfg.ownerValueRegex, "owner-value-regex", "", "Regular expression the -owner-freeform-key value must match to count as an owner")
+	fs.BoolVar(&cfg.archiveOutput, "archive", false, "Zip this run's output files into <output-dir>/audit_<timestamp>.zip")
+	fs.BoolVar(&cfg.archiveCleanup, "archive-cleanup", false, "Delete the loose output files once -archive has succeeded")
+	fs.BoolVar(&cfg.tagConflicts, "tag-conflicts", false, "Create a separate file for resources whose owner tags disagree")
+	fs.StringVar(&cfg.ownerEquivalents, "owner-equivalents", "*.CreatedBy,freeform:owner", "Comma-separated tags that should carry the same owner: Namespace.Key, *.Key or freeform:Key")
+	fs.StringVar(&cfg.costTagList, "cost-tags", "", "Comma-separated Namespace.Key cost-allocation tags to report on, e.g. Finance.CostCenter,Finance.Project")
+	fs.StringVar(&cfg.userAgentSuffix, "user-agent-suffix", "", "Extra text appended to the tool's user agent on every OCI API call")
+	fs.Var(&cfg.resourceOCIDs, "resource-ocid", "Only look up this resource OCID and report its compliance (repeatable)")
+	fs.BoolVar(&cfg.combinedReport, "combined", false, "Also write every region's resources into one combined file")
+	fs.IntVar(&cfg.combinedBuffer, "combined-buffer", 1000, "Rows buffered for the combined file before region scans wait for the writer")
+	fs.StringVar(&cfg.homeRegionKey, "home-region-key", "", "Home region key of the DEFAULT tenancy, e.g. IAD; skips the GetTenancy lookup")
+	fs.BoolVar(&cfg.skipHomeRegion, "skip-home-region", false, "Skip all GetTenancy lookups; home regions and tenancy names are unknown")
+	fs.StringVar(&cfg.flattenTagList, "flatten-tags", "", "Comma-separated defined tags (Namespace.Key) to add as one report column each, holding the tag's value")
+	fs.StringVar(&cfg.resourceTypeList, "resource-types", "", "Comma-separated resource types to audit, e.g. instance,vcn,bucket, instead of a -query (default: all)")
+	fs.StringVar(&cfg.logLevel, "log-level", "info", "Minimum level logged: debug, info, warn or error")
+	fs.StringVar(&cfg.logFormat, "log-format", "text", "Log format: text or json")
+	fs.BoolVar(&cfg.dedupReportFlag, "dedup", false, "Also write all regions' resources into one file with each OCID once")
+	fs.BoolVar(&cfg.writeMetrics, "metrics", false, "Write a Prometheus textfile of per-region resource, missing tag and no owner gauges")
+	fs.StringVar(&cfg.queryFile, "query-file", "", "File holding the structured search query for every region, instead of -query")
+	fs.DurationVar(&cfg.progressInterval, "progress", 0, "Log the running resource count of every region at this interval, e.g. 30s (0 = off)")
+	fs.StringVar(&cfg.regionList, "region", "", "Search every selected section in this region instead of its configured one; with a comma-separated list, in each listed region")
+	fs.BoolVar(&cfg.showNamespaces, "show-namespaces", false, "Add a Tag Namespaces column listing the defined-tag namespaces of each resource")
+	fs.BoolVar(&cfg.exceptionsOnly, "exceptions-only", false, "Write only the -missing-tags and -no-owner reports, without the main report of every resource")
+	fs.StringVar(&cfg.compartmentID, "compartment-id", "", "Only report resources in this compartment and the compartments below it")
+	fs.BoolVar(&cfg.compartmentExact, "compartment-exact", false, "With -compartment-id, leave out the compartments below it")
+	fs.StringVar(&cfg.webhookURL, "webhook-url", "", "POST a Slack-compatible JSON summary of missing tag and no owner counts per region to this URL after the run")
+	fs.BoolVar(&cfg.webhookAlways, "webhook-always", false, "With -webhook-url, also post when no resource has missing tags or no owner")
+	fs.StringVar(&cfg.metricsFile, "metrics-file", "", "With -metrics, also replace this file with the metrics, e.g. in the node_exporter textfile directory")
+	fs.IntVar(&cfg.minAgeDays, "min-age-days", 0, "Only report resources created at least N days ago (0 = all)")
+	fs.BoolVar(&cfg.includeUnknownAge, "include-unknown-age", false, "With -min-age-days or -since, also report resources without a creation time")
+	fs.BoolVar(&cfg.resolveCompartments, "compartment-names", false, "Add a Compartment Name column, listing each tenancy's compartments once (extra identity API calls)")
+	fs.IntVar(&cfg.regionConcurrency, "concurrency", 4, "Maximum number of regions scanned at once; the others wait for a free slot (-max-concurrency with -adaptive-concurrency)")
+	fs.BoolVar(&cfg.dryRun, "dry-run", false, "Run the searches and log the counts and the files that would be written, without writing any")
+	fs.BoolVar(&cfg.gzipOutput, "gzip", false, "Compress the CSV, JSON and JSONL output files with gzip, adding .gz to their names (not the metrics file or xlsx workbook)")
+	fs.StringVar(&cfg.uploadBucket, "upload-bucket", "", "Upload this run's output files to this Object Storage bucket")
+	fs.StringVar(&cfg.uploadNamespace, "upload-namespace", "", "Object Storage namespace of -upload-bucket (default: looked up from the DEFAULT profile)")
+	fs.StringVar(&cfg.uploadPrefix, "upload-prefix", "", "Object name prefix for uploaded files, e.g. audits/ (sets -object-prefix)")
+	fs.IntVar(&cfg.uploadPartSize, "upload-part-size", 128, "Files larger than this many MiB are uploaded in parts of this size")
+	fs.DurationVar(&cfg.timeout, "timeout", 0, "Stop the scan after this long, e.g. 30m, keeping the partial reports (0 = no limit)")
+	fs.StringVar(&cfg.requiredTagList, "required-tags", "", "Comma-separated defined tags (Namespace.Key) every resource must carry; missing ones are listed in the missing tags file (implies -missing-tags)")
+	fs.StringVar(&cfg.requiredTagPolicyFile, "required-tags-policy", "", "JSON file (not YAML) of required defined tags per resource type, with a default list for other types (implies -missing-tags)")
+	fs.StringVar(&cfg.configFile, "config", "~/.oci/config", "OCI config file, or a comma-separated list whose sections are all audited (when not set, the path in config_path.txt is used if that file exists)")
+	fs.StringVar(&cfg.authMode, "auth", authConfig, "Authentication: config (profiles of the -config files, or of the file in config_path.txt when -config is not set), instance (instance principal) or resource (resource principal)")
+	fs.IntVar(&cfg.maxRetries, "max-retries", 5, "Retries of a search request failing with a throttling, server or network error, with exponential backoff")
+	fs.Float64Var(&cfg.requestRate, "rate", 5, "Maximum search requests per second in each region (0 = no limit)")
+	fs.Var(&cfg.derivedColumnSpecs, "derived-column", "Extra report column computed per row, as Name=expression, e.g. 'Team=upper(split(displayName, \"-\", 0))' (repeatable)")
+	fs.StringVar(&cfg.outputFormat, "format", formatCSV, "Format of the main, missing tags and no owner reports: csv, json, jsonl, or xlsx for one workbook")
+	fs.StringVar(&cfg.sortBy, "sort-by", "", "Write the main, missing tags, no owner and combined reports sorted by identifier, displayname, timecreated, resourcetype or compartment, holding each report in memory until it is complete")
+	fs.BoolVar(&cfg.changesMarkdown, "markdown", false, "Write the report-changes changelog as Markdown")
+	fs.StringVar(&cfg.weightTagName, "weight-tag", "", "Defined tag (Namespace.Key) whose value weights each resource in the compliance score")
+	fs.StringVar(&cfg.weightList, "weights", "", "Comma-separated value=weight pairs for -weight-tag, e.g. prod=3,staging=2 (other values weigh 1)")
+	fs.BoolVar(&cfg.compartmentCompliance, "compartment-compliance", false, "Create a report of the share of resources in each compartment that carry all their required tags, least compliant first")
+	fs.StringVar(&cfg.profileName, "profile", "", "Audit only the config section with this name (case-insensitive) instead of all sections")
+	fs.StringVar(&cfg.searchQuery, "query", "", "Structured search query for every region, replacing the -settings query (default \""+defaultQuery+"\")")
+	fs.IntVar(&cfg.maxConsecutiveEmpty, "max-consecutive-empty", 0, "Stop a region's search after this many empty pages in a row that still have a next page (0 = no limit)")
+	fs.IntVar(&cfg.maxPages, "max-pages", 0, "Stop a region's search after this many pages, as a safety cap (0 = no limit)")
+	fs.StringVar(&cfg.tenanciesFile, "tenancies-file", "", "CSV of tenancy OCID, profile and optional ';'-separated regions to audit instead of the config sections")
+	fs.BoolVar(&cfg.strictJSON, "strict-json", false, "Fail resources whose tags or names cannot be serialized faithfully instead of writing empty or altered values")
+	fs.BoolVar(&cfg.ownerDefaultsCheck, "owner-defaults", false, "Annotate no-owner resources with whether a CreatedBy tag default should have applied (one identity call per compartment)")
+	fs.BoolVar(&cfg.adaptiveConcurrency, "adaptive-concurrency", false, "Adapt the number of regions searching at once to throttling (AIMD), between -min-concurrency and -max-concurrency")
+	fs.IntVar(&cfg.minConcurrency, "min-concurrency", 1, "Lower bound, and starting point, of -adaptive-concurrency")
+	fs.IntVar(&cfg.maxConcurrency, "max-concurrency", 8, "Upper bound of -adaptive-concurrency")
+	fs.BoolVar(&cfg.splitByType, "split-by-type", false, "Also write each resource type to its own file, <region>_<Type>_<timestamp>.csv")
+	fs.IntVar(&cfg.maxOpenTypeFiles, "max-open-type-files", 64, "Maximum number of -split-by-type files open at once across all regions")
+	fs.IntVar(&cfg.maxCellLength, "max-cell-length", 0, "Truncate report cells longer than N characters (0 = no limit)")
+	fs.BoolVar(&cfg.cellOverflow, "cell-overflow", false, "With -max-cell-length, write the full values of truncated cells to a separate file keyed by OCID")
+	fs.BoolVar(&cfg.partitionByDate, "partition-by-date", false, "Write output below <output-dir>/year=YYYY/month=MM/day=DD/ for data-lake tools")
+	fs.StringVar(&cfg.dataDir, "output-dir", "data", "Directory for all output files")
+	fs.BoolVar(&cfg.runDirFlag, "run-dir", false, "Group each run's files in <output-dir>/run_<timestamp>/ (below the date partition with -partition-by-date)")
+	fs.IntVar(&cfg.graceDays, "grace-days", 0, "Exclude resources created less than N days ago from compliance checks, marking them In Grace Period")
+	fs.StringVar(&cfg.postHook, "post-hook", "", "Command run for each generated file once it is closed, with {file} replaced by its path")
+	fs.IntVar(&cfg.postHookConcurrency, "post-hook-concurrency", 4, "Maximum number of -post-hook commands run at once")
+	fs.StringVar(&cfg.environmentTagName, "environment-tag", "", "Defined tag (Namespace.Key) holding each resource's environment, for the Environment column and per-environment summaries")
+	fs.BoolVar(&cfg.checksums, "checksums", false, "Write a SHA-256 .sha256 sidecar for every output file and record the digests in the manifest")
+	fs.BoolVar(&cfg.pagePrefetch, "parallel-page-prefetch", false, "Fetch the next search page while the current one is written (still one request in flight)")
+	fs.StringVar(&cfg.labelBy, "label-by", "section", "Label output by config \"section\" name or by the section's actual \"region\"")
+	fs.BoolVar(&cfg.tagsBoth, "tags-both", false, "Add a Defined Tags (flat) column, Namespace.Key=Value pairs, next to the Defined Tags JSON")
+	fs.IntVar(&cfg.lookupConcurrency, "lookup-concurrency", 4, "Maximum number of tenancy lookups run at once when sections span several tenancies")
+	fs.StringVar(&cfg.ociLoggingID, "oci-logging-id", "", "OCID of an OCI Logging custom log to send per-region compliance summaries to")
+	fs.BoolVar(&cfg.ociLoggingViolations, "oci-logging-violations", false, "With -oci-logging-id, also send one entry per non-compliant resource")
+	fs.IntVar(&cfg.minTags, "min-tags", 0, "Report resources with fewer than N defined tags as under-tagged in the missing tags file (implies -missing-tags)")
+	fs.BoolVar(&cfg.writeIndex, "index", false, "Write a JSON index of this run's objects and a latest.json pointer to it")
+	fs.StringVar(&cfg.objectPrefix, "object-prefix", "", "Object name prefix used for the objects listed in the run index")
+	fs.BoolVar(&cfg.globalFromHomeOnly, "global-from-home-only", false, "Only report global resource types (IAM, tag namespaces) from the home region so they are counted once")
+	fs.StringVar(&cfg.baselineFile, "baseline-compliance", "", "Add a Status Change column comparing each resource's compliance with this earlier report")
+	fs.BoolVar(&cfg.checkRetired, "check-retired-namespaces", false, "Create a separate file for resources still tagged in retired tag namespaces (extra identity API calls)")
+	fs.BoolVar(&cfg.auditTagDefaults, "audit-tag-defaults", false, "Report compartments lacking a tag default for the owner or a required tag instead of scanning resources")
+}
+
+// sortedIndexes returns the keys of an index map in increasing order.
+func sortedIndexes(m map[int]string) []int {
+	indexes := make([]int, 0, len(m))
+	for i := range m {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// splitList splits a comma-separated flag value, dropping blank entries.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// isTransitional reports whether a resource is mid-operation, in which case
+// its tags may legitimately be incomplete.
+func (cfg *config) isTransitional(r ResourceSummary) bool {
+	return cfg.transitionalStates[strings.ToUpper(getStringValue(r.LifecycleState))]
+}
+
+func DefinedTagsToString(dt map[string]map[string]interface{}) string {
+	bytes, err := json.Marshal(dt)
+	if err != nil {
+		return ""
+	}
+	return string(bytes)
+}
+
+// FlattenDefinedTags formats defined tags as "Namespace.Key=Value" pairs
+// joined by "; ", sorted so equal tags always give equal output.
+func FlattenDefinedTags(dt map[string]map[string]interface{}) string {
+	var parts []string
+	for namespace, tags := range dt {
+		for key, value := range tags {
+			parts = append(parts, fmt.Sprintf("%s.%s=%v", namespace, key, value))
+		}
+	}
+
+	sort.Strings(parts)
+	return strings.Join(parts, "; ")
+}
+
+// freeformEscaper escapes the characters FreeformTagsToString uses as
+// separators, so keys and values containing them parse back unchanged.
+var freeformEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, `,`, `\,`)
+
+// FreeformTagsToString writes freeform tags as sorted "key=value" pairs
+// separated by ", ". Backslashes, equals signs and commas in keys and values
+// are escaped with a backslash; tags without them read as before.
+func FreeformTagsToString(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+
+	var parts []string
+	for k, v := range tags {
+		parts = append(parts, freeformEscaper.Replace(k)+"="+freeformEscaper.Replace(v))
+	}
+
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// definedTagCount returns the number of defined tags across all namespaces.
+func definedTagCount(definedTags map[string]map[string]interface{}) int {
+	count := 0
+	for _, tags := range definedTags {
+		count += len(tags)
+	}
+	return count
+}
+
+// missingTagsNote reports whether a resource has too few defined tags: none
+// at all, fewer than -min-tags when set, or not all the tags required for
+// its type. The note describes what is missing.
+func (cfg *config) missingTagsNote(r ResourceSummary) (bool, string) {
+	var notes []string
+	count := definedTagCount(r.DefinedTags)
+	if note := cfg.tagCountNote(count); note != "" {
+		notes = append(notes, note)
+	}
+	if missing := hasRequiredTags(r.DefinedTags, cfg.requiredTagsFor(getStringValue(r.ResourceType))); len(missing) > 0 && count > 0 {
+		notes = append(notes, "missing required tags "+tagRefNames(missing))
+	}
+	return len(notes) > 0, strings.Join(notes, "; ")
+}
+
+// tagCountNote describes a defined tag count that is too low: none at all,
+// or fewer than -min-tags when set. It is empty for a sufficient count.
+func (cfg *config) tagCountNote(count int) string {
+	switch {
+	case count == 0:
+		return "no defined tags"
+	case count < cfg.minTags:
+		return fmt.Sprintf("%d defined tags, fewer than %d", count, cfg.minTags)
+	}
+	return ""
+}
+
+// requiredTagsFor returns the defined tags a resource type must carry: its
+// -required-tags-policy list, or the default list for types without one.
+func (cfg *config) requiredTagsFor(resourceType string) []tagRef {
+	if tags, ok := cfg.requiredTagsByType[strings.ToLower(resourceType)]; ok {
+		return tags
+	}
+	return cfg.requiredTags
+}
+
+// checksRequiredTags reports whether any resource type has required tags,
+// so the missing tags file needs a Missing Required Tags column.
+func (cfg *config) checksRequiredTags() bool {
+	if len(cfg.requiredTags) > 0 {
+		return true
+	}
+	for _, tags := range cfg.requiredTagsByType {
+		if len(tags) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRequiredTags returns the required tags a resource does not carry, or
+// carries with a blank value. Namespaces and keys match case-insensitively.
+func hasRequiredTags(definedTags map[string]map[string]interface{}, required []tagRef) []tagRef {
+	var missing []tagRef
+	for _, t := range required {
+		if value, ok := definedTagValue(definedTags, t.namespace, t.key); !ok || strings.TrimSpace(value) == "" {
+			missing = append(missing, t)
+		}
+	}
+	return missing
+}
+
+// tagRefNames joins the names of tag references with ", ".
+func tagRefNames(refs []tagRef) string {
+	names := make([]string, len(refs))
+	for i, t := range refs {
+		names[i] = t.name()
+	}
+	return strings.Join(names, ", ")
+}
+
+// hasCreatedByTag reports whether the defined tags carry an owner: the
+// -owner-tag tag, or without it a CreatedBy key in any namespace. Values
+// listed in -owner-placeholders count as absent.
+func (cfg *config) hasCreatedByTag(definedTags map[string]map[string]interface{}) bool {
+	if len(definedTags) == 0 {
+		return false
+	}
+
+	if cfg.ownerTag != nil {
+		value, ok := definedTagValue(definedTags, cfg.ownerTag.namespace, cfg.ownerTag.key)
+		return ok && cfg.isOwnerValue(value)
+	}
+	for _, namespace := range definedTags {
+		for key, value := range namespace {
+			if strings.EqualFold(key, ownerTagKey) {
+				if strVal, ok := value.(string); ok && cfg.isOwnerValue(strVal) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func formatTimeCreated(sdkTime *common.SDKTime) (string, string) {
+	if sdkTime == nil {
+		return "N/A", "N/A"
+	}
+
+	formattedTime := sdkTime.Time.UTC().Format("2006-01-02 15:04:05")
+	days, _ := daysSinceCreation(sdkTime)
+	return formattedTime, fmt.Sprintf("%d", days)
+}
+
+// parseSince parses the -since cutoff: an RFC3339 time, or a date taken as
+// midnight UTC.
+func parseSince(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither RFC3339 nor YYYY-MM-DD", value)
+	}
+	return t, nil
+}
+
+// daysSinceCreation returns the number of whole days since a resource was
+// created, and false when the creation time is unknown.
+func daysSinceCreation(sdkTime *common.SDKTime) (int, bool) {
+	if sdkTime == nil {
+		return 0, false
+	}
+	return int(time.Since(sdkTime.Time).Hours() / 24), true
+}
+
+// inGracePeriod reports whether a resource is younger than -grace-days, in
+// which case automation may not have tagged it yet.
+func (cfg *config) inGracePeriod(r ResourceSummary) bool {
+	days, known := daysSinceCreation(r.TimeCreated)
+	return cfg.graceDays > 0 && known && days < cfg.graceDays
+}
+
+// exemptFromChecks reports whether a resource is left out of compliance
+// checks: in flight or in its grace period.
+func (cfg *config) exemptFromChecks(r ResourceSummary) bool {
+	return cfg.isTransitional(r) || cfg.inGracePeriod(r)
+}
+
+// TenancyInfo identifies a tenancy and its home region.
+type TenancyInfo struct {
+	TenancyID     string `json:"tenancy_id"`
+	TenancyName   string `json:"tenancy_name"`
+	HomeRegionKey string `json:"home_region_key"`
+}
+
+// GetHomeRegionKeyFromDefaultConfig looks up the tenancy of the given
+// profile, usually DEFAULT, in an OCI config file.
+func (cfg *config) GetHomeRegionKeyFromDefaultConfig(ctx context.Context, configPath, profile string) (TenancyInfo, error) {
+	idClient, tenancyID, err := cfg.newIdentityClient(configPath, profile)
+	if err != nil {
+		return TenancyInfo{}, err
+	}
+
+	req := identity.GetTenancyRequest{TenancyId: &tenancyID}
+	resp, err := idClient.GetTenancy(ctx, req)
+	if err != nil {
+		return TenancyInfo{}, fmt.Errorf("GetTenancy call failed: %w", err)
+	}
+
+	if resp.Tenancy.HomeRegionKey == nil {
+		return TenancyInfo{}, fmt.Errorf("tenancy response missing HomeRegionKey")
+	}
+	return TenancyInfo{
+		TenancyID:     tenancyID,
+		TenancyName:   getStringValue(resp.Tenancy.Name),
+		HomeRegionKey: *resp.Tenancy.HomeRegionKey,
+	}, nil
+}
+
+// writesMissingTags reports whether the missing tags report is written:
+// with -missing-tags or any of the tag checks it lists.
+func (cfg *config) writesMissingTags() bool {
+	return cfg.createMissingTagsFile || cfg.minTags > 0 || cfg.requiredTagList != "" || cfg.requiredTagPolicyFile != ""
+}
+
+// loadChecks sets up the owner and tag rule checks of complianceReasons
+// from the flags.
+func (cfg *config) loadChecks() error {
+	cfg.createMissingTagsFile = cfg.writesMissingTags()
+
+	cfg.transitionalStates = make(map[string]bool)
+	for _, state := range splitList(cfg.transitionalStateList) {
+		cfg.transitionalStates[strings.ToUpper(state)] = true
+	}
+
+	var err error
+	if cfg.requiredTags, err = parseTagRefs(cfg.requiredTagList); err != nil {
+		return fmt.Errorf("error parsing -required-tags: %w", err)
+	}
+	if cfg.requiredTagPolicyFile != "" {
+		var fallback []tagRef
+		cfg.requiredTagsByType, fallback, err = loadRequiredTagPolicy(cfg.requiredTagPolicyFile)
+		if err != nil {
+			return fmt.Errorf("error loading required tags policy: %w", err)
+		}
+		if fallback != nil {
+			if len(cfg.requiredTags) > 0 {
+				return fmt.Errorf("-required-tags cannot be combined with a -required-tags-policy default list")
+			}
+			cfg.requiredTags = fallback
+		}
+		slog.Info("Loaded required tags policy", "types", len(cfg.requiredTagsByType), "default", tagRefNames(cfg.requiredTags), "path", cfg.requiredTagPolicyFile)
+	}
+	if cfg.flattenTags, err = parseTagRefs(cfg.flattenTagList); err != nil {
+		return fmt.Errorf("error parsing -flatten-tags: %w", err)
+	}
+
+	if cfg.ownerTagName != "" {
+		namespace, key, err := parseTagRef(cfg.ownerTagName)
+		if err != nil {
+			return fmt.Errorf("error parsing -owner-tag: %w", err)
+		}
+		cfg.ownerTag = &tagRef{namespace: namespace, key: key}
+	}
+	cfg.ownerPlaceholders = make(map[string]bool)
+	for _, value := range splitList(cfg.ownerPlaceholderList) {
+		cfg.ownerPlaceholders[strings.ToLower(value)] = true
+	}
+
+	if cfg.ownerValueRegex != "" {
+		if cfg.ownerFreeformKey == "" {
+			return fmt.Errorf("-owner-value-regex requires -owner-freeform-key")
+		}
+		cfg.ownerValuePattern, err = regexp.Compile(cfg.ownerValueRegex)
+		if err != nil {
+			return fmt.Errorf("error compiling -owner-value-regex: %w", err)
+		}
+	}
+
+	if cfg.tagRulesFile != "" {
+		cfg.tagRules, err = loadTagRules(cfg.tagRulesFile)
+		if err != nil {
+			return fmt.Errorf("error loading tag rules: %w", err)
+		}
+		slog.Info("Loaded tag rules", "rules", len(cfg.tagRules), "path", cfg.tagRulesFile)
+	}
+	return nil
+}
+
+// selectProfiles returns the config sections to audit: all but DEFAULT, or
+// only the one named by -profile, matched case-insensitively.
+func (cfg *config) selectProfiles(file *ini.File) ([]string, error) {
+	var profiles []string
+	for _, section := range file.Sections() {
+		name := section.Name()
+		if name == "DEFAULT" {
+			continue
+		}
+		if cfg.profileName == "" || strings.EqualFold(name, cfg.profileName) {
+			profiles = append(profiles, name)
+		}
+	}
+	if cfg.profileName != "" && len(profiles) == 0 {
+		return nil, fmt.Errorf("-profile %q matches no section of the config file", cfg.profileName)
+	}
+	return profiles, nil
+}
+
+// flagSet reports whether a flag was given on the command line or by Run,
+// to tell an empty value apart from a missing one.
+func (cfg *config) flagSet(name string) bool {
+	set := false
+	cfg.flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// homeRegionKeyPattern loosely matches a region key such as "IAD" or "FRA".
+var homeRegionKeyPattern = regexp.MustCompile(`^[A-Za-z]{3}$`)
+
+// defaultTenancy returns the tenancy of the DEFAULT profile. With
+// -home-region-key or -skip-home-region the GetTenancy call is skipped and
+// only the tenancy OCID is read from the config file, so the tenancy name
+// is unknown.
+func (cfg *config) defaultTenancy(ctx context.Context, configPath string) (TenancyInfo, error) {
+	if cfg.homeRegionKey == "" && !cfg.skipHomeRegion {
+		return cfg.GetHomeRegionKeyFromDefaultConfig(ctx, configPath, "DEFAULT")
+	}
+	if cfg.homeRegionKey != "" && !homeRegionKeyPattern.MatchString(cfg.homeRegionKey) {
+		return TenancyInfo{}, fmt.Errorf("invalid -home-region-key %q: expected a three-letter region key such as IAD", cfg.homeRegionKey)
+	}
+
+	tenancyID, err := cfg.profileTenancyID(configPath, "DEFAULT")
+	if err != nil {
+		return TenancyInfo{}, err
+	}
+	if cfg.skipHomeRegion {
+		slog.Info("Skipping the tenancy lookup (-skip-home-region); home region unknown")
+		return TenancyInfo{TenancyID: tenancyID}, nil
+	}
+	slog.Info("Skipping the tenancy lookup; using -home-region-key", "home_region_key", strings.ToUpper(cfg.homeRegionKey))
+	return TenancyInfo{TenancyID: tenancyID, HomeRegionKey: strings.ToUpper(cfg.homeRegionKey)}, nil
+}
+
+// legacyConfigPathFile names the OCI config file when -config is not set.
+const legacyConfigPathFile = "config_path.txt"
+
+// resolveConfigPaths returns the OCI config files to use: the
+// comma-separated -config list if given, else the path in config_path.txt
+// if that file exists, else ~/.oci/config. A leading "~/" is expanded. The
+// choice is logged.
+func (cfg *config) resolveConfigPaths() ([]string, error) {
+	paths, source := splitList(cfg.configFile), "-config"
+	if !cfg.flagSet("config") {
+		if _, err := os.Stat(legacyConfigPathFile); err == nil {
+			path, err := ReadFirstLine(legacyConfigPathFile)
+			if err != nil && !errors.Is(err, errEmptyFile) {
+				return nil, fmt.Errorf("%s: %w", legacyConfigPathFile, err)
+			}
+			if path = strings.TrimSpace(path); path == "" {
+				return nil, legacyConfigPathError()
+			}
+			paths, source = []string{path}, legacyConfigPathFile
+		} else {
+			source = "default"
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("-config names no file")
+	}
+
+	seen := make(map[string]bool)
+	for i, path := range paths {
+		if path == "~" || strings.HasPrefix(path, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, fmt.Errorf("error expanding %s: %w", path, err)
+			}
+			paths[i] = filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+		if seen[paths[i]] {
+			return nil, fmt.Errorf("-config lists %s twice", paths[i])
+		}
+		seen[paths[i]] = true
+		slog.Info("Using config file", "path", paths[i], "source", source)
+	}
+	return paths, nil
+}
+
+// configPrefix labels the output of a config file's sections when several
+// -config files are audited: the file's base name without its extension,
+// e.g. "prod" for ~/.oci/prod.config.
+func configPrefix(path string) string {
+	base := filepath.Base(path)
+	if trimmed := strings.TrimSuffix(base, filepath.Ext(base)); trimmed != "" {
+		base = trimmed
+	}
+	return fileNameSafe(base) + "_"
+}
+
+// legacyConfigPathError explains an empty config_path.txt, naming the file
+// it read and the ways out.
+func legacyConfigPathError() error {
+	dir, err := os.Getwd()
+	if err != nil {
+		dir = "."
+	}
+	return fmt.Errorf("%s in %s is empty: its first line must be the path to an OCI config file, such as /home/opc/.oci/config; "+
+		"write the path there, delete the file to use ~/.oci/config, or pass -config", legacyConfigPathFile, dir)
+}
+
+// errEmptyFile is returned by ReadFirstLine for a file without lines.
+var errEmptyFile = errors.New("file is empty")
+
+func ReadFirstLine(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("error opening file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if scanner.Scan() {
+		return scanner.Text(), nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("error reading file: %w", err)
+	}
+
+	return "", errEmptyFile
+}
+
+// fileNameSafe replaces characters that are awkward in file names.
+func fileNameSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// sortedKeys returns the keys of a count map in sorted order.
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func getStringValue(ptr *string) string {
+	if ptr == nil {
+		return ""
+	}
+	return *ptr
+}
+
+// ExecuteFullSearch scans one target with a search client for region,
+// writes its reports and returns its tallies for the run summary. An error
+// means the scan stopped early; the reports keep the rows written until
+// then.
+func ExecuteFullSearch(ctx context.Context, run *auditRun, client SearchClient, region string, target searchTarget, query string) (*regionTally, error) {
+	profile := target.profile
+
+	// section labels the output: file names, the Region column and hooks.
+	section := target.label
+	switch {
+	case section != "":
+	case run.cfg.labelBy == "region":
+		section = region
+	default:
+		section = profile
+	}
+	section = target.prefix + section
+
+	skipGlobal := false
+	if run.cfg.globalFromHomeOnly {
+		homeRegion := run.tenancies.homeRegionFor(target.configPath, profile)
+		if homeRegion == "" {
+			slog.Warn("Home region unknown, global resources are not skipped", "region", section)
+		}
+		skipGlobal = homeRegion != "" && region != homeRegion
+	}
+
+	// Create output directory if it doesn't exist
+	if !run.cfg.dryRun {
+		if err := os.MkdirAll(run.outputDir(), 0755); err != nil {
+			return nil, fmt.Errorf("error creating data directory: %w", err)
+		}
+	}
+
+	if run.progress != nil {
+		run.progress.begin(section)
+		defer run.progress.finish(section)
+	}
+
+	report := newRegionReport(run, section)
+	headers := report.headers
+	defer report.Close()
+
+	// Create main report file
+	var err error
+	if !run.cfg.exceptionsOnly {
+		if report.main, err = run.openFormattedReport(section, "resources", headers); err != nil {
+			return nil, fmt.Errorf("error creating main report file: %w", err)
+		}
+	}
+
+	// Initialize optional report files
+	if run.cfg.createMissingTagsFile {
+		missingHeaders := headers
+		if run.cfg.minTags > 0 {
+			missingHeaders = append(append([]string{}, headers...), "Defined Tag Count")
+		}
+		if run.cfg.checksRequiredTags() {
+			missingHeaders = append(append([]string{}, missingHeaders...), "Missing Required Tags")
+		}
+		if report.missingTags, err = run.openFormattedReport(section, "missing_tags", missingHeaders); err != nil {
+			return nil, fmt.Errorf("error creating missing tags file: %w", err)
+		}
+	}
+
+	if run.cfg.createNoOwnerFile {
+		noOwnerHeaders := headers
+		if run.cfg.ownerFreeformKey != "" {
+			noOwnerHeaders = append(append([]string{}, noOwnerHeaders...), "Owner Note")
+		}
+		if run.cfg.ownerDefaults != nil {
+			noOwnerHeaders = append(append([]string{}, noOwnerHeaders...), "Owner Default")
+		}
+		if report.noOwner, err = run.openFormattedReport(section, "no_owner", noOwnerHeaders); err != nil {
+			return nil, fmt.Errorf("error creating no owner file: %w", err)
+		}
+	}
+
+	if len(run.cfg.tagRules) > 0 {
+		invalidHeaders := append(append([]string{}, headers...), "Tag", "Value", "Violation")
+		if report.invalidTags, err = run.openReport(run.reportPath(section, "invalid_tags"), invalidHeaders); err != nil {
+			return nil, fmt.Errorf("error creating invalid tags file: %w", err)
+		}
+	}
+
+	if run.cfg.tagConflicts {
+		conflictHeaders := append(append([]string{}, headers...), "Conflicting Sources")
+		if report.conflicts, err = run.openReport(run.reportPath(section, "tag_conflicts"), conflictHeaders); err != nil {
+			return nil, fmt.Errorf("error creating tag conflicts file: %w", err)
+		}
+	}
+
+	if len(run.cfg.costTags) > 0 {
+		if report.costTags, err = run.openReport(run.reportPath(section, "cost_tags"), run.cfg.costTagHeaders()); err != nil {
+			return nil, fmt.Errorf("error creating cost tags file: %w", err)
+		}
+	}
+
+	if run.cfg.splitByType {
+		report.byType = newTypeFiles(run, section, headers)
+	}
+
+	if run.cfg.maxCellLength > 0 && run.cfg.cellOverflow {
+		if report.overflow, err = run.openReport(run.reportPath(section, "cell_overflow"), []string{"Identifier", "Column", "Value"}); err != nil {
+			return nil, fmt.Errorf("error creating cell overflow file: %w", err)
+		}
+	}
+
+	if run.cfg.retiredStatus != nil {
+		retiredHeaders := append(append([]string{}, headers...), "Retired Namespaces")
+		if report.retired, err = run.openReport(run.reportPath(section, "retired_namespaces"), retiredHeaders); err != nil {
+			return nil, fmt.Errorf("error creating retired namespaces file: %w", err)
+		}
+	}
+
+	if run.cfg.deltaReport {
+		priorPath, found, err := run.findPriorReport(section)
+		if err != nil {
+			return nil, fmt.Errorf("error locating prior report for %s: %w", section, err)
+		}
+		if found {
+			if report.prior, err = loadPriorReport(priorPath); err != nil {
+				return nil, fmt.Errorf("error loading prior report for %s: %w", section, err)
+			}
+			slog.Debug("Comparing against the prior report", "region", section, "path", priorPath)
+		} else {
+			slog.Info("No prior report found, every resource is reported as new", "region", section)
+		}
+
+		deltaHeaders := append(append([]string{}, headers...), "Change")
+		if report.delta, err = run.openReport(run.reportPath(section, "delta"), deltaHeaders); err != nil {
+			return nil, fmt.Errorf("error creating delta file: %w", err)
+		}
+		report.seen = make(map[string]bool)
+	}
+
+	// The run-wide reports and user hooks run after the region's reports.
+	hooks := chainHooks(run.hooks, run.cfg.userHooks)
+
+	// Perform resource search
+	request := resourcesearch.SearchResourcesRequest{
+		SearchDetails: resourcesearch.StructuredSearchDetails{
+			Query: common.String(query),
+		},
+		Limit: common.Int(1000),
+	}
+
+	pages := newPager(ctx, run.cfg, section, client, request, run.cfg.pagePrefetch, run.limiter)
+	defer pages.close()
+
+	var skipped, skippedGlobal, strictFailures int
+	capped := false
+	// truncated is why the region's scan ended before its last page, for
+	// the run summary.
+	var truncated string
+	for !capped {
+		response, more, err := pages.next()
+		if !more {
+			break
+		}
+		if err != nil {
+			if run.isTruncated() {
+				slog.Info("Stopped early, -max-total reached", "region", section)
+				truncated = run.cfg.truncation("max-total")
+				break
+			}
+			if ctx.Err() != nil {
+				slog.Warn("Stopped early", "region", section, "error", ctx.Err())
+				truncated = "stopped before the last page"
+				break
+			}
+			return nil, fmt.Errorf("error searching resources in %s: %w", section, err)
+		}
+
+		pageItems := 0
+		for _, resource := range response.Items {
+			if run.cfg.debugOCID != "" && getStringValue(resource.Identifier) == run.cfg.debugOCID {
+				run.dumpResource(section, response.OpcRequestId, resource)
+			}
+			if skipGlobal && isGlobalType(resource) {
+				skippedGlobal++
+				continue
+			}
+			if !run.include(resource) {
+				continue
+			}
+			if run.cfg.strictJSON {
+				if err := checkSerializable(resource); err != nil {
+					slog.Warn("Strict JSON: failing resource", "region", section, "ocid", getStringValue(resource.Identifier), "error", err)
+					strictFailures++
+					continue
+				}
+			}
+			row, err := buildRowSafely(report.columns, section, resource)
+			if err != nil {
+				slog.Warn("Skipping resource", "region", section, "ocid", getStringValue(resource.Identifier), "error", err)
+				skipped++
+				continue
+			}
+			// Only resources that are written use up -max-total.
+			if !run.reserve() {
+				slog.Info("Stopped early, -max-total reached", "region", section)
+				truncated = run.cfg.truncation("max-total")
+				capped = true
+				break
+			}
+			// Resources whose main row could not be written are left out of
+			// the run-wide reports and user hooks too.
+			if !report.writeResource(section, resource, row) {
+				run.unreserve()
+				continue
+			}
+			if hooks.OnResource != nil {
+				hooks.OnResource(section, resource)
+			}
+			pageItems++
+			// Only written rows count; resources filtered out above do not.
+			if run.cfg.maxResources > 0 && report.totalResources >= run.cfg.maxResources {
+				slog.Info("Stopped early, -max-resources reached; the region's reports are partial", "region", section, "max_resources", run.cfg.maxResources)
+				report.limited = true
+				run.markLimited()
+				truncated = run.cfg.truncation("max-resources")
+				capped = true
+				break
+			}
+		}
+		// Rows are written as each page is processed and flushed once it is
+		// done, so memory does not grow with the number of resources.
+		if err := report.Flush(); err != nil {
+			return nil, fmt.Errorf("error writing reports: %w", err)
+		}
+		report.pages++
+		if hooks.OnPage != nil {
+			hooks.OnPage(section, pageItems)
+		}
+	}
+	// The loop may stop before the last page; the prefetch goroutine must
+	// be done with the counters before they are read.
+	pages.close()
+
+	if pages.emptyPages > 0 {
+		slog.Debug("Empty pages with a next page", "region", section, "pages", pages.emptyPages)
+	}
+	if pages.stoppedEmpty {
+		slog.Warn("Stopped after too many consecutive empty pages; the region's reports are partial", "region", section, "max_consecutive_empty", run.cfg.maxConsecutiveEmpty)
+		report.limited = true
+		run.markEmptyLimited()
+		truncated = run.cfg.truncation("max-consecutive-empty")
+	}
+	if pages.stoppedRepeat {
+		slog.Warn("Stopped, the search returned the page just requested as the next page; the region's reports are partial", "region", section, "pages", report.pages)
+		report.limited = true
+		run.markRepeatStopped()
+		truncated = run.cfg.truncation("repeat")
+	}
+	if pages.stoppedMaxPages && !capped {
+		slog.Warn("Stopped early, -max-pages reached; the region's reports are partial", "region", section, "max_pages", run.cfg.maxPages)
+		report.limited = true
+		run.markPageLimited()
+		truncated = run.cfg.truncation("max-pages")
+	}
+	report.tally.truncated = truncated
+
+	if report.delta != nil {
+		// Resources outside a partial scan are not gone, so only a complete
+		// scan can report removals.
+		if run.isTruncated() || report.limited || !run.since.IsZero() || run.cfg.minAgeDays > 0 || run.compartments != nil || pages.stoppedEmpty || pages.stoppedRepeat || ctx.Err() != nil {
+			slog.Info("Partial scan, removed resources are not reported in the delta", "region", section)
+		} else {
+			report.writeRemoved()
+		}
+	}
+
+	slog.Info("Processed resources", "region", section, "resources", report.totalResources, "pages", report.pages)
+	if run.cfg.dryRun {
+		slog.Info("Dry run counts", "region", section, "resources", report.tally.counts.resources,
+			"missing_tags", report.tally.counts.missingTags, "no_owner", report.tally.counts.noOwner)
+	}
+	if report.writeErrors > 0 {
+		slog.Warn("Rows could not be written", "region", section, "write_errors", report.writeErrors)
+	}
+	if skipped > 0 {
+		slog.Warn("Skipped malformed resources", "region", section, "resources", skipped)
+	}
+	if strictFailures > 0 {
+		slog.Error("FAILED strict JSON checks, reports are incomplete", "region", section, "resources", strictFailures)
+		run.manifest.addStrictFailures(section, strictFailures)
+	}
+	if skippedGlobal > 0 {
+		slog.Info("Skipped global resources, reported from the home region", "region", section, "resources", skippedGlobal)
+	}
+	if report.inFlightCount > 0 {
+		slog.Info("In-flight resources excluded from compliance checks", "region", section, "resources", report.inFlightCount)
+	}
+	if report.byType != nil {
+		slog.Info("Wrote per-type files", "region", section, "files", report.byType.count())
+	}
+	if report.truncatedCount > 0 {
+		slog.Info("Truncated cells", "region", section, "resources", report.truncatedCount, "max_cell_length", run.cfg.maxCellLength)
+	}
+	if report.graceCount > 0 {
+		slog.Info("Resources in their grace period excluded from compliance checks", "region", section, "resources", report.graceCount, "grace_days", run.cfg.graceDays)
+	}
+	if run.cfg.createMissingTagsFile && run.cfg.minTags > 0 {
+		slog.Info("Found resources with too few defined tags", "region", section, "resources", report.missingTagsCount, "min_tags", run.cfg.minTags)
+	} else if run.cfg.createMissingTagsFile {
+		slog.Info("Found resources with missing tags", "region", section, "resources", report.missingTagsCount)
+	}
+	if run.cfg.createNoOwnerFile {
+		slog.Info("Found resources with no owner", "region", section, "resources", report.noOwnerCount)
+	}
+	if len(run.cfg.tagRules) > 0 {
+		slog.Info("Found resources with invalid tag values", "region", section, "resources", report.invalidTagsCount)
+	}
+	if run.cfg.tagConflicts {
+		slog.Info("Found resources with conflicting owner tags", "region", section, "resources", report.conflictCount)
+	}
+	if len(run.cfg.costTags) > 0 {
+		slog.Info("Found resources missing cost tags", "region", section, "resources", report.missingCostCount)
+	}
+	if report.retired != nil {
+		slog.Info("Found resources tagged in retired namespaces", "region", section, "resources", report.retiredCount)
+	}
+	if report.delta != nil {
+		slog.Info("Delta", "region", section, "new", report.deltaCounts["new"],
+			"changed", report.deltaCounts["changed"], "removed", report.deltaCounts["removed"])
+	}
+	if run.cfg.byReason {
+		for _, id := range sortedKeys(report.reasonCounts) {
+			slog.Info("Found resources failing a check", "region", section, "resources", report.reasonCounts[id], "reason", id)
+		}
+	}
+
+	if run.ociLog != nil {
+		run.ociLog.add(section, "summary", report.summary(skipped))
+	}
+	return report.tally, nil
+}
+
+// regionReport holds the report files and tallies for one region. Its
+// writeResource method writes a resource's rows, before the OnResource
+// hooks run. It reports whether the resource's main row was written.
+type regionReport struct {
+	run     *auditRun
+	cfg     *config
+	section string
+	columns []column
+	headers []string
+
+	main        *reportFile
+	missingTags *reportFile
+	noOwner     *reportFile
+	invalidTags *reportFile
+	conflicts   *reportFile
+	costTags    *reportFile
+	retired     *reportFile
+	overflow    *reportFile
+	byType      *typeFiles
+	delta       *reportFile
+
+	// prior is the previous report compared against by -delta, or nil on
+	// the first run; seen collects the OCIDs of this scan.
+	prior *priorReport
+	seen  map[string]bool
+
+	// reasonFiles are the -by-reason worklists, created on first use.
+	reasonFiles map[string]*reportFile
+
+	totalResources int
+	// pages counts the search pages processed.
+	pages int
+	// limited is set when the region stopped at -max-resources or
+	// -max-pages.
+	limited bool
+	// writeErrors counts the rows that could not be written to a report.
+	writeErrors      int
+	missingTagsCount int
+	noOwnerCount     int
+	invalidTagsCount int
+	conflictCount    int
+	missingCostCount int
+	retiredCount     int
+	inFlightCount    int
+	graceCount       int
+	truncatedCount   int
+	reasonCounts     map[string]int
+	deltaCounts      map[string]int
+
+	// tally counts every resource for the run summary, whichever
+	// report files are enabled.
+	tally *regionTally
+}
+
+// newRegionReport returns the tallies of a region, with the columns of
+// the current flags and no report files open.
+func newRegionReport(run *auditRun, section string) *regionReport {
+	columns := run.cfg.reportColumns()
+	return &regionReport{
+		run:          run,
+		cfg:          run.cfg,
+		section:      section,
+		columns:      columns,
+		headers:      columnHeaders(columns),
+		reasonFiles:  make(map[string]*reportFile),
+		reasonCounts: make(map[string]int),
+		deltaCounts:  make(map[string]int),
+		tally:        newRegionTally(section),
+	}
+}
+
+func (r *regionReport) writeResource(section string, resource ResourceSummary, row []string) bool {
+
+	// Keep oversized cells, usually tags, from breaking CSV parsers
+	var full map[int]string
+	if r.cfg.maxCellLength > 0 {
+		full = truncateCells(row, r.cfg.maxCellLength)
+	}
+
+	// Write to main report, which -exceptions-only leaves out. A row that
+	// cannot be written is left out of every other report and count, so
+	// they all cover the same resources.
+	if r.main != nil && !r.writeRow(r.main, "main", row) {
+		return false
+	}
+	r.totalResources++
+	if len(full) > 0 {
+		r.truncatedCount++
+	}
+	if r.overflow != nil {
+		for _, i := range sortedIndexes(full) {
+			r.writeRow(r.overflow, "cell overflow", []string{getStringValue(resource.Identifier), r.headers[i], full[i]})
+		}
+	}
+	if r.run.combined != nil {
+		r.run.combined.Write(row)
+	}
+	if r.run.dedup != nil {
+		r.run.dedup.add(r.section, row)
+	}
+	if r.byType != nil {
+		if err := r.byType.write(getStringValue(resource.ResourceType), row); err != nil {
+			r.writeErrors++
+			slog.Error("Error writing report", "region", r.section, "report", "per-type", "error", err)
+		}
+	}
+
+	// Record tag changes since the prior report
+	if r.delta != nil {
+		ocid := getStringValue(resource.Identifier)
+		r.seen[ocid] = true
+		if change := r.prior.classifyChange(ocid, resourceFingerprint(resource)); change != "" {
+			if r.writeRow(r.delta, "delta", append(append([]string{}, row...), change)) {
+				r.deltaCounts[change]++
+			}
+		}
+	}
+
+	// Report cost allocation tags
+	if r.costTags != nil {
+		costRow, complete := r.cfg.costTagRow(section, resource)
+		if r.writeRow(r.costTags, "cost tags", costRow) && !complete {
+			r.missingCostCount++
+		}
+	}
+
+	// Flag tags in retired namespaces
+	if r.retired != nil {
+		if retired := r.cfg.retiredStatus.retiredNamespaces(resource.DefinedTags); len(retired) > 0 {
+			if r.writeRow(r.retired, "retired namespaces", append(append([]string{}, row...), strings.Join(retired, ", "))) {
+				r.retiredCount++
+			}
+		}
+	}
+
+	// In-flight resources are listed but not checked for compliance
+	if r.cfg.isTransitional(resource) {
+		r.inFlightCount++
+		r.tally.add(getStringValue(resource.ResourceType), false, false)
+		return true
+	}
+
+	// So are resources automation may not have tagged yet
+	if r.cfg.inGracePeriod(resource) {
+		r.graceCount++
+		r.tally.add(getStringValue(resource.ResourceType), false, false)
+		return true
+	}
+
+	missing, _ := r.cfg.missingTagsNote(resource)
+	hasOwner, note := r.cfg.ownerStatus(resource)
+	r.tally.add(getStringValue(resource.ResourceType), missing, !hasOwner)
+	if r.cfg.compartmentCompliance {
+		resourceType := getStringValue(resource.ResourceType)
+		compliant := len(hasRequiredTags(resource.DefinedTags, r.cfg.requiredTagsFor(resourceType))) == 0
+		r.tally.addCompliance(getStringValue(resource.CompartmentId), compliant)
+	}
+
+	// Check for missing tags
+	if r.cfg.createMissingTagsFile && missing {
+		missingRow := row
+		if r.cfg.minTags > 0 {
+			missingRow = append(append([]string{}, row...), fmt.Sprintf("%d", definedTagCount(resource.DefinedTags)))
+		}
+		if r.cfg.checksRequiredTags() {
+			missingRow = append(append([]string{}, missingRow...), tagRefNames(hasRequiredTags(resource.DefinedTags, r.cfg.requiredTagsFor(getStringValue(resource.ResourceType)))))
+		}
+		if r.writeRow(r.missingTags, "missing tags", missingRow) {
+			r.missingTagsCount++
+		}
+	}
+
+	// Check for missing owner
+	if r.cfg.createNoOwnerFile && !hasOwner {
+		noOwnerRow := row
+		if r.cfg.ownerFreeformKey != "" {
+			noOwnerRow = append(append([]string{}, noOwnerRow...), note)
+		}
+		if r.cfg.ownerDefaults != nil {
+			noOwnerRow = append(append([]string{}, noOwnerRow...), r.cfg.ownerDefaults.ownerDefaultNote(getStringValue(resource.CompartmentId)))
+		}
+		if r.writeRow(r.noOwner, "no owner", noOwnerRow) {
+			r.noOwnerCount++
+		}
+	}
+
+	// Check tag values against the configured rules
+	if violations := checkTagRules(resource.DefinedTags, r.cfg.tagRules); len(violations) > 0 {
+		written := false
+		for _, v := range violations {
+			if r.writeRow(r.invalidTags, "invalid tags", append(append([]string{}, row...), v.tag, v.value, v.reason)) {
+				written = true
+			}
+		}
+		if written {
+			r.invalidTagsCount++
+		}
+	}
+
+	// Check that all owner sources agree
+	if r.cfg.tagConflicts {
+		if details, conflict := ownerConflict(resource, r.cfg.ownerSources); conflict {
+			if r.writeRow(r.conflicts, "tag conflicts", append(append([]string{}, row...), details)) {
+				r.conflictCount++
+			}
+		}
+	}
+
+	// Split non-compliant resources into one worklist per reason
+	if r.cfg.byReason {
+		for _, reason := range r.cfg.complianceReasons(resource) {
+			if err := r.writeReason(reason, row); err != nil {
+				r.writeErrors++
+				slog.Error("Error writing report", "region", r.section, "report", "reason", "reason", reason.id, "error", err)
+			} else {
+				r.reasonCounts[reason.id]++
+			}
+		}
+	}
+	return true
+}
+
+// writeRow writes a row to one of the region's reports. A failure is
+// logged and counted in writeErrors.
+func (r *regionReport) writeRow(f *reportFile, report string, row []string) bool {
+	if err := f.Write(row); err != nil {
+		r.writeErrors++
+		slog.Error("Error writing report", "region", r.section, "report", report, "error", err)
+		return false
+	}
+	return true
+}
+
+// summary returns the region's counts for the OCI Logging summary entry.
+// Counts of checks that are turned off are left out.
+func (r *regionReport) summary(skipped int) map[string]interface{} {
+	summary := map[string]interface{}{
+		"processed":       r.totalResources,
+		"skipped":         skipped,
+		"in_flight":       r.inFlightCount,
+		"in_grace_period": r.graceCount,
+		"truncated":       r.run.isTruncated() || r.limited,
+		"write_errors":    r.writeErrors,
+		"pages":           r.pages,
+	}
+	if r.missingTags != nil {
+		summary["missing_tags"] = r.missingTagsCount
+	}
+	if r.noOwner != nil {
+		summary["no_owner"] = r.noOwnerCount
+	}
+	if r.invalidTags != nil {
+		summary["invalid_tags"] = r.invalidTagsCount
+	}
+	if r.conflicts != nil {
+		summary["owner_conflicts"] = r.conflictCount
+	}
+	if r.costTags != nil {
+		summary["missing_cost_tags"] = r.missingCostCount
+	}
+	if r.retired != nil {
+		summary["retired_namespaces"] = r.retiredCount
+	}
+	if r.cfg.byReason {
+		summary["reasons"] = r.reasonCounts
+	}
+	return summary
+}
+
+// writeRemoved adds the resources of the prior report that were not seen
+// in this scan to the delta report.
+func (r *regionReport) writeRemoved() {
+	for _, ocid := range r.prior.removed(r.seen) {
+		row := append(r.prior.rowFor(ocid, r.headers), "removed")
+		if err := r.delta.Write(row); err != nil {
+			slog.Error("Error writing to delta report", "error", err)
+			continue
+		}
+		r.deltaCounts["removed"]++
+	}
+}
+
+func (r *regionReport) writeReason(reason complianceReason, row []string) error {
+	file, ok := r.reasonFiles[reason.id]
+	if !ok {
+		headers := append(append([]string{}, r.headers...), "Reason")
+		var err error
+		if file, err = r.run.openReport(r.run.reportPath(r.section, "reason_"+reason.id), headers); err != nil {
+			return err
+		}
+		r.reasonFiles[reason.id] = file
+	}
+	return file.Write(append(append([]string{}, row...), reason.details))
+}
+
+// Close flushes and closes every open report file of the region.
+// files returns the region's open reports, other than the per-type files.
+func (r *regionReport) files() []*reportFile {
+	var files []*reportFile
+	for _, f := range []*reportFile{r.main, r.missingTags, r.noOwner, r.invalidTags, r.conflicts, r.costTags, r.retired, r.overflow, r.delta} {
+		if f != nil {
+			files = append(files, f)
+		}
+	}
+	for _, f := range r.reasonFiles {
+		files = append(files, f)
+	}
+	return files
+}
+
+// Flush writes the buffered rows of every open report to its file, so a
+// page's rows are on disk before the next page is processed.
+func (r *regionReport) Flush() error {
+	for _, f := range r.files() {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+	if r.byType != nil {
+		return r.byType.Flush()
+	}
+	return nil
+}
+
+func (r *regionReport) Close() {
+	if r.byType != nil {
+		r.byType.Close()
+	}
+	for _, f := range r.files() {
+		if err := f.Close(); err != nil {
+			slog.Error("Error closing report", "region", r.section, "error", err)
+		}
+	}
+}
+
+// validateFlags checks the options that need neither a file nor the API,
+// so that an invalid value or combination fails before anything is looked
+// up, started or written.
+func (cfg *config) validateFlags() error {
+	if strings.TrimSpace(cfg.dataDir) == "" {
+		return fmt.Errorf("-output-dir must not be empty")
+	}
+	if cfg.timeout < 0 {
+		return fmt.Errorf("-timeout must not be negative")
+	}
+	switch cfg.authMode {
+	case authConfig:
+	case authInstance, authResource:
+		if cfg.tenanciesFile != "" {
+			return fmt.Errorf("-tenancies-file selects config profiles and needs -auth %s", authConfig)
+		}
+	default:
+		return fmt.Errorf("unknown -auth %q, expected config, instance or resource", cfg.authMode)
+	}
+	if cfg.requestRate < 0 {
+		return fmt.Errorf("-rate must not be negative")
+	}
+	if cfg.maxRetries < 0 {
+		return fmt.Errorf("-max-retries must not be negative")
+	}
+	if cfg.regionConcurrency < 1 {
+		return fmt.Errorf("-concurrency must be at least 1")
+	}
+	if cfg.lookupConcurrency < 1 {
+		return fmt.Errorf("-lookup-concurrency must be at least 1")
+	}
+	if cfg.adaptiveConcurrency && (cfg.minConcurrency < 1 || cfg.maxConcurrency < cfg.minConcurrency) {
+		return fmt.Errorf("-adaptive-concurrency needs 1 <= -min-concurrency <= -max-concurrency")
+	}
+	if cfg.splitByType && cfg.maxOpenTypeFiles < 1 {
+		return fmt.Errorf("-max-open-type-files must be at least 1")
+	}
+	if cfg.progressInterval < 0 {
+		return fmt.Errorf("-progress must not be negative")
+	}
+	if cfg.combinedReport && cfg.combinedBuffer < 1 {
+		return fmt.Errorf("-combined-buffer must be at least 1")
+	}
+
+	switch cfg.outputFormat {
+	case formatCSV:
+	case formatJSON, formatJSONL, formatXLSX:
+		if cfg.deltaReport {
+			return fmt.Errorf("-delta compares CSV main reports and cannot be used with -format %s", cfg.outputFormat)
+		}
+	default:
+		return fmt.Errorf("unknown -format %q, expected csv, json, jsonl or xlsx", cfg.outputFormat)
+	}
+	if cfg.sortBy != "" {
+		cfg.sortBy = strings.ToLower(cfg.sortBy)
+		header, ok := sortKeys[cfg.sortBy]
+		if !ok {
+			return fmt.Errorf("unknown -sort-by %q, expected identifier, displayname, timecreated, resourcetype or compartment", cfg.sortBy)
+		}
+		for _, c := range baseColumns {
+			if c.header == header && cfg.minimalFields && !c.minimal {
+				return fmt.Errorf("-sort-by %s needs the %s column, which -minimal-fields leaves out", cfg.sortBy, header)
+			}
+		}
+	}
+	switch cfg.labelBy {
+	case "section", "region":
+	default:
+		return fmt.Errorf("-label-by must be \"section\" or \"region\", got %q", cfg.labelBy)
+	}
+
+	if cfg.postHook != "" {
+		if err := validatePostHook(cfg.postHook); err != nil {
+			return fmt.Errorf("invalid -post-hook: %w", err)
+		}
+		if cfg.postHookConcurrency < 1 {
+			return fmt.Errorf("-post-hook-concurrency must be at least 1")
+		}
+	}
+	if cfg.dryRun {
+		for _, name := range []string{"archive", "checksums", "index", "upload-bucket", "post-hook"} {
+			if cfg.flagSet(name) {
+				return fmt.Errorf("-dry-run cannot be combined with -%s", name)
+			}
+		}
+	}
+	if cfg.uploadBucket != "" {
+		if cfg.uploadPartSize < 1 {
+			return fmt.Errorf("-upload-part-size must be at least 1")
+		}
+	} else if cfg.uploadNamespace != "" || cfg.uploadPrefix != "" {
+		return fmt.Errorf("-upload-namespace and -upload-prefix require -upload-bucket")
+	}
+	if cfg.uploadPrefix != "" && cfg.flagSet("object-prefix") && cfg.objectPrefix != cfg.uploadPrefix {
+		return fmt.Errorf("-upload-prefix %q and -object-prefix %q differ", cfg.uploadPrefix, cfg.objectPrefix)
+	}
+	if cfg.weightTagName == "" && cfg.weightList != "" {
+		return fmt.Errorf("-weights requires -weight-tag")
+	}
+	if cfg.metricsFile != "" && !cfg.writeMetrics {
+		return fmt.Errorf("-metrics-file requires -metrics")
+	}
+	if cfg.exceptionsOnly && !cfg.writesMissingTags() && !cfg.createNoOwnerFile {
+		return fmt.Errorf("-exceptions-only writes only the -missing-tags and -no-owner reports and needs at least one of them")
+	}
+	if cfg.webhookAlways && cfg.webhookURL == "" {
+		return fmt.Errorf("-webhook-always requires -webhook-url")
+	}
+	if cfg.webhookURL != "" && !strings.HasPrefix(cfg.webhookURL, "https://") && !strings.HasPrefix(cfg.webhookURL, "http://") {
+		return fmt.Errorf("-webhook-url must be an http or https URL")
+	}
+
+	if cfg.queryFile != "" {
+		if cfg.flagSet("query") {
+			return fmt.Errorf("-query-file and -query cannot be combined; put the query in one of them")
+		}
+		if cfg.resourceTypeList != "" {
+			return fmt.Errorf("-query-file and -resource-types cannot be combined")
+		}
+	}
+	if cfg.resourceTypeList != "" && cfg.flagSet("query") {
+		return fmt.Errorf("-resource-types and -query cannot be combined")
+	}
+	if len(splitList(cfg.regionList)) > 0 && cfg.tenanciesFile != "" {
+		return fmt.Errorf("-region cannot be used with -tenancies-file, which lists the regions of each tenancy")
+	}
+	if cfg.compartmentExact && cfg.compartmentID == "" {
+		return fmt.Errorf("-compartment-exact requires -compartment-id")
+	}
+	if cfg.compartmentID != "" && !strings.HasPrefix(cfg.compartmentID, "ocid1.compartment.") && !strings.HasPrefix(cfg.compartmentID, "ocid1.tenancy.") {
+		return fmt.Errorf("-compartment-id %q is not a compartment OCID", cfg.compartmentID)
+	}
+	if cfg.sinceDate != "" && cfg.sinceLastRun {
+		return fmt.Errorf("-since and -since-last-run cannot be combined")
+	}
+	if cfg.minAgeDays < 0 {
+		return fmt.Errorf("-min-age-days must not be negative")
+	}
+	if cfg.includeUnknownAge && cfg.minAgeDays == 0 && cfg.sinceDate == "" {
+		return fmt.Errorf("-include-unknown-age requires -min-age-days or -since")
+	}
+	return nil
+}
+
+// execute runs an audit with the registered options. command is "" for a
+// scan or "tenancy-info". scanned, when not nil, is called once every
+// region has finished, before the reports are summarized. The options are
+// validated and parsed before the tenancy is looked up.
+func (cfg *config) execute(ctx context.Context, command string, scanned func()) (Report, error) {
+	if err := cfg.validateFlags(); err != nil {
+		return Report{}, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Cancelling ctx or -timeout stops the scan like -max-total does: every
+	// region stops at its next request and the reports written so far are
+	// flushed, summarized and listed in the manifest.
+	if cfg.timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, cfg.timeout)
+		defer cancelTimeout()
+	}
+
+	var (
+		// configPath is the first -config file. It has the DEFAULT profile
+		// used for the tenancy lookup and the tenancy-wide checks.
+		configPath  string
+		configPaths []string
+		err         error
+	)
+	if cfg.authMode == authConfig {
+		configPaths, err = cfg.resolveConfigPaths()
+		if err != nil {
+			return Report{}, fmt.Errorf("error reading config path: %w", err)
+		}
+		configPath = configPaths[0]
+		if len(configPaths) > 1 && cfg.tenanciesFile != "" {
+			return Report{}, fmt.Errorf("-tenancies-file names profiles of one config file and cannot be used with several -config files")
+		}
+		if len(configPaths) > 1 && len(cfg.resourceOCIDs) > 0 {
+			return Report{}, fmt.Errorf("-resource-ocid searches the sections of one config file and cannot be used with several -config files")
+		}
+	} else {
+		slog.Info("Authenticating with a principal", "auth", cfg.authMode)
+		configPaths = []string{configPath}
+	}
+
+	if err := cfg.loadChecks(); err != nil {
+		return Report{}, err
+	}
+
+	for _, spec := range cfg.derivedColumnSpecs {
+		c, err := parseDerivedColumn(spec)
+		if err != nil {
+			return Report{}, fmt.Errorf("error parsing -derived-column: %w", err)
+		}
+		all := layoutOptions{environment: true, flatTags: true, grace: true, statusChange: true, compartmentNames: true}
+		existingColumns := cfg.columnsFor(all)
+		for _, t := range cfg.flattenTags {
+			existingColumns = append(existingColumns, tagColumn(t))
+		}
+		for _, existing := range append(existingColumns, cfg.derivedColumns...) {
+			if strings.EqualFold(existing.header, c.header) {
+				return Report{}, fmt.Errorf("-derived-column %s duplicates the %s column", c.header, existing.header)
+			}
+		}
+		cfg.derivedColumns = append(cfg.derivedColumns, c)
+	}
+
+	if cfg.tagConflicts {
+		cfg.ownerSources, err = parseTagSources(cfg.ownerEquivalents)
+		if err != nil {
+			return Report{}, fmt.Errorf("error parsing -owner-equivalents: %w", err)
+		}
+		if len(cfg.ownerSources) < 2 {
+			return Report{}, fmt.Errorf("-tag-conflicts needs at least two -owner-equivalents to compare")
+		}
+	}
+
+	cfg.costTags, err = parseTagRefs(cfg.costTagList)
+	if err != nil {
+		return Report{}, fmt.Errorf("error parsing -cost-tags: %w", err)
+	}
+
+	if cfg.uploadPrefix != "" {
+		// Uploaded objects and the run index use the same names.
+		cfg.objectPrefix = cfg.uploadPrefix
+	}
+
+	if cfg.environmentTagName != "" {
+		namespace, key, err := parseTagRef(cfg.environmentTagName)
+		if err != nil {
+			return Report{}, fmt.Errorf("error parsing -environment-tag: %w", err)
+		}
+		cfg.environmentTag = &tagRef{namespace: namespace, key: key}
+	}
+
+	if cfg.weightTagName != "" {
+		namespace, key, err := parseTagRef(cfg.weightTagName)
+		if err != nil {
+			return Report{}, fmt.Errorf("error parsing -weight-tag: %w", err)
+		}
+		cfg.weightTag = &tagRef{namespace: namespace, key: key}
+		if cfg.weightValues, err = parseWeights(cfg.weightList); err != nil {
+			return Report{}, fmt.Errorf("error parsing -weights: %w", err)
+		}
+	}
+	if cfg.compartmentCompliance && !cfg.checksRequiredTags() {
+		return Report{}, fmt.Errorf("-compartment-compliance requires -required-tags or -required-tags-policy")
+	}
+
+	// The baseline is evaluated with the current checks, so it is loaded
+	// after the tag rules and owner settings.
+	if cfg.baselineFile != "" {
+		cfg.baseline, err = cfg.loadBaseline(cfg.baselineFile)
+		if err != nil {
+			return Report{}, fmt.Errorf("error loading baseline report: %w", err)
+		}
+		slog.Info("Loaded compliance baseline", "resources", len(cfg.baseline.violating), "path", cfg.baselineFile)
+	}
+
+	var settings *Settings
+	if cfg.settingsFile != "" {
+		settings, err = loadSettings(cfg.settingsFile)
+		if err != nil {
+			return Report{}, fmt.Errorf("error loading settings: %w", err)
+		}
+	}
+	if cfg.queryFile != "" {
+		bytes, err := os.ReadFile(cfg.queryFile)
+		if err != nil {
+			return Report{}, fmt.Errorf("error reading -query-file: %w", err)
+		}
+		if cfg.searchQuery = strings.TrimRightFunc(string(bytes), unicode.IsSpace); strings.TrimSpace(cfg.searchQuery) == "" {
+			return Report{}, fmt.Errorf("-query-file %s is empty; it must hold a structured search query such as %q", cfg.queryFile, defaultQuery)
+		}
+		slog.Info("Read query", "path", cfg.queryFile)
+	}
+	if cfg.resourceTypeList != "" {
+		query, unknown, err := resourceTypesQuery(cfg.resourceTypeList)
+		if err != nil {
+			return Report{}, fmt.Errorf("invalid -resource-types: %w", err)
+		}
+		for _, t := range unknown {
+			slog.Warn("Unknown resource type in -resource-types; the search may reject it", "resource_type", t)
+		}
+		slog.Info("Querying resource types", "query", query)
+		cfg.searchQuery = query
+	}
+	if cfg.flagSet("query") || cfg.queryFile != "" || cfg.resourceTypeList != "" {
+		if err := validateQuery(cfg.searchQuery); err != nil {
+			return Report{}, fmt.Errorf("invalid -query: %w", err)
+		}
+		if settings == nil {
+			settings = &Settings{}
+		}
+		settings.Query = strings.TrimSpace(cfg.searchQuery)
+	}
+
+	tenancy, err := cfg.defaultTenancy(ctx, configPath)
+	if err != nil {
+		return Report{}, fmt.Errorf("error retrieving HomeRegionKey: %w", err)
+	}
+	if tenancy.HomeRegionKey != "" {
+		slog.Info("Tenancy looked up", "home_region_key", tenancy.HomeRegionKey)
+	}
+
+	if command == "tenancy-info" {
+		bytes, err := json.MarshalIndent(tenancy, "", "  ")
+		if err != nil {
+			return Report{}, fmt.Errorf("error encoding tenancy info: %w", err)
+		}
+		fmt.Println(string(bytes))
+		return Report{}, nil
+	}
+
+	run := newAuditRun(cfg, cancel)
+	run.tenancies.add(configPath, "DEFAULT", tenancy)
+	if cfg.outputFormat == formatXLSX {
+		run.workbook = newXLSXWorkbook()
+	}
+	if cfg.partitionByDate {
+		run.setPartitionByDate()
+	}
+	if cfg.runDirFlag {
+		run.setRunDir()
+	}
+	if cfg.adaptiveConcurrency {
+		run.limiter = newAIMDLimiter(cfg.minConcurrency, cfg.maxConcurrency)
+	}
+	if cfg.splitByType {
+		run.typeFileSlots = make(chan struct{}, cfg.maxOpenTypeFiles)
+	}
+
+	if cfg.prefixTenancy {
+		name := cfg.tenancyName
+		if name == "" {
+			name = tenancy.TenancyName
+		}
+		if name == "" {
+			return Report{}, fmt.Errorf("-prefix-tenancy needs a tenancy name; set -tenancy-name")
+		}
+		run.filePrefix = fileNameSafe(name) + "_"
+		slog.Info("Prefixing output files", "prefix", run.filePrefix)
+	}
+
+	if cfg.auditTagDefaults {
+		run.manifest.Kind = runKindTagDefaults
+		if err := AuditTagDefaults(ctx, run, configPath); err != nil {
+			return Report{}, fmt.Errorf("error auditing tag defaults: %w", err)
+		}
+		if err := run.finish(); err != nil {
+			slog.Error("Error writing run manifest", "error", err)
+		}
+		return run.report(nil), nil
+	}
+
+	var defaultConfig *ini.File
+	if cfg.authMode == authConfig {
+		defaultConfig, err = ini.Load(configPath)
+	} else {
+		defaultConfig, err = cfg.principalConfig()
+	}
+	if err != nil {
+		return Report{}, fmt.Errorf("error loading config file: %w", err)
+	}
+
+	if cfg.sinceDate != "" {
+		cutoff, err := parseSince(cfg.sinceDate)
+		if err != nil {
+			return Report{}, fmt.Errorf("invalid -since: %w", err)
+		}
+		if cutoff.After(run.manifest.StartedAt) {
+			slog.Warn("-since is in the future, no resource will be reported", "since", cutoff.Format(time.RFC3339))
+		}
+		run.setSince(cutoff)
+		run.sinceKnownOnly = true
+		slog.Info("Scoping audit to resources created since", "since", cutoff.Format(time.RFC3339))
+	}
+	if cfg.sinceLastRun {
+		cutoff, found, err := run.lastRunStart()
+		switch {
+		case err != nil:
+			return Report{}, fmt.Errorf("error reading previous run: %w", err)
+		case found:
+			run.setSince(cutoff)
+			slog.Info("Scoping audit to resources created since the last run", "since", cutoff.Format(time.RFC3339))
+		default:
+			slog.Info("No previous run found, performing a full scan")
+		}
+	}
+
+	var coverage *tagCoverage
+	if cfg.tagCoverageReport {
+		coverage = newTagCoverage(cfg)
+		run.hooks = chainHooks(run.hooks, Hooks{OnResource: coverage.onResource})
+	}
+
+	var namespaces *namespaceUsage
+	if cfg.namespaceUsageReport {
+		namespaces = newNamespaceUsage()
+		run.hooks = chainHooks(run.hooks, Hooks{OnResource: namespaces.onResource})
+	}
+
+	var costs *costCoverage
+	if len(cfg.costTags) > 0 {
+		costs = newCostCoverage(cfg)
+		run.hooks = chainHooks(run.hooks, Hooks{OnResource: costs.onResource})
+	}
+
+	if cfg.ociLoggingID != "" {
+		if run.ociLog, err = cfg.newOCILogger(configPath, cfg.ociLoggingID, tenancy); err != nil {
+			slog.Warn("Not sending results to OCI Logging", "error", err)
+		} else if cfg.ociLoggingViolations {
+			run.hooks = chainHooks(run.hooks, Hooks{OnResource: run.ociLog.onViolation})
+		}
+	}
+
+	if cfg.ownerDefaultsCheck {
+		idClient, tenancyID, err := cfg.newIdentityClient(configPath, "DEFAULT")
+		if err == nil {
+			cfg.ownerDefaults, err = cfg.loadCompartmentDefaults(ctx, idClient, tenancyID)
+		}
+		if err != nil {
+			slog.Warn("Skipping the owner default check, tag defaults could not be read", "error", err)
+		} else {
+			slog.Info("Loaded tag defaults", "compartments", len(cfg.ownerDefaults.compartments))
+		}
+	}
+
+	var retired *retiredUsage
+	if cfg.checkRetired {
+		idClient, tenancyID, err := cfg.newIdentityClient(configPath, "DEFAULT")
+		if err == nil {
+			cfg.retiredStatus, err = loadNamespaceStatus(ctx, idClient, tenancyID)
+		}
+		if err != nil {
+			slog.Warn("Skipping the retired namespace check, tag namespaces could not be read (does the user have tag namespace read permission?)", "error", err)
+		} else {
+			slog.Info("Found retired tag namespaces", "namespaces", len(cfg.retiredStatus.retired))
+			retired = newRetiredUsage(cfg.retiredStatus)
+			run.hooks = chainHooks(run.hooks, Hooks{OnResource: retired.onResource})
+		}
+	}
+
+	var environments *environmentSummary
+	if cfg.environmentTag != nil {
+		environments = newEnvironmentSummary(cfg)
+		run.hooks = chainHooks(run.hooks, Hooks{OnResource: environments.onResource})
+	}
+
+	var score *complianceScore
+	if cfg.weightTag != nil {
+		score = newComplianceScore(cfg, *cfg.weightTag, cfg.weightValues)
+		run.hooks = chainHooks(run.hooks, Hooks{OnResource: score.onResource})
+	}
+
+	var inventory *typeInventory
+	if cfg.typeInventoryReport {
+		inventory = newTypeInventory()
+		run.hooks = chainHooks(run.hooks, Hooks{OnResource: inventory.onResource})
+	}
+
+	// With several -config files each file's sections are prefixed with the
+	// file's base name, which must therefore differ.
+	configs := map[string]*ini.File{configPath: defaultConfig}
+	prefixes := map[string]string{configPath: ""}
+	sectionNames := make(map[string]bool)
+	if len(configPaths) > 1 {
+		owners := make(map[string]string)
+		for _, path := range configPaths {
+			prefix := configPrefix(path)
+			if other, ok := owners[prefix]; ok {
+				return Report{}, fmt.Errorf("config files %s and %s have the same base name, which labels their output", other, path)
+			}
+			owners[prefix] = path
+			prefixes[path] = prefix
+			if configs[path] == nil {
+				if configs[path], err = ini.Load(path); err != nil {
+					return Report{}, fmt.Errorf("error loading config file %s: %w", path, err)
+				}
+			}
+		}
+	}
+	for _, path := range configPaths {
+		for _, section := range configs[path].SectionStrings() {
+			sectionNames[section] = true
+			sectionNames[prefixes[path]+section] = true
+		}
+	}
+	if settings != nil {
+		for region := range settings.RegionQueries {
+			if !sectionNames[region] {
+				slog.Warn("region_queries entry matches no config section", "entry", region)
+			}
+		}
+	}
+
+	if len(cfg.resourceOCIDs) > 0 {
+		sections, err := cfg.selectProfiles(defaultConfig)
+		if err != nil {
+			return Report{}, fmt.Errorf("error selecting profiles: %w", err)
+		}
+		run.manifest.Kind = runKindLookup
+		if err := LookupResources(ctx, run, configPath, sections, cfg.resourceOCIDs); err != nil {
+			return Report{}, fmt.Errorf("error looking up resources: %w", err)
+		}
+		if err := run.finish(); err != nil {
+			slog.Error("Error writing run manifest", "error", err)
+		}
+		return run.report(nil), nil
+	}
+
+	var regionOverrides []string
+	for _, region := range splitList(cfg.regionList) {
+		regionOverrides = append(regionOverrides, string(common.StringToRegion(region)))
+	}
+
+	var (
+		profiles []string
+		targets  []searchTarget
+		entries  []tenancyEntry
+		// byConfig lists the selected profiles of each config file.
+		byConfig = make(map[string][]string)
+	)
+	if cfg.tenanciesFile != "" {
+		if entries, err = loadTenanciesFile(cfg.tenanciesFile); err != nil {
+			return Report{}, fmt.Errorf("error loading tenancies file: %w", err)
+		}
+		if cfg.profileName != "" {
+			var selected []tenancyEntry
+			for _, entry := range entries {
+				if strings.EqualFold(entry.profile, cfg.profileName) {
+					selected = append(selected, entry)
+				}
+			}
+			if len(selected) == 0 {
+				return Report{}, fmt.Errorf("-profile %q matches no profile in %s", cfg.profileName, cfg.tenanciesFile)
+			}
+			entries = selected
+		}
+		for _, entry := range entries {
+			profiles = append(profiles, entry.profile)
+		}
+		byConfig[configPath] = profiles
+		slog.Info("Auditing tenancies", "tenancies", len(entries), "path", cfg.tenanciesFile)
+	} else {
+		for _, path := range configPaths {
+			selected, err := cfg.selectProfiles(configs[path])
+			if err != nil {
+				// -profile has to match a section of one of the files.
+				if len(configPaths) > 1 && cfg.profileName != "" {
+					continue
+				}
+				return Report{}, fmt.Errorf("error selecting profiles: %w", err)
+			}
+			for _, profile := range selected {
+				profiles = append(profiles, prefixes[path]+profile)
+				target := searchTarget{configPath: path, profile: profile, prefix: prefixes[path]}
+				switch {
+				case len(regionOverrides) == 1:
+					target.region = regionOverrides[0]
+					targets = append(targets, target)
+				case len(regionOverrides) > 1:
+					// One search per region, labeled like -tenancies-file
+					// searches so their files stay apart.
+					for _, region := range regionOverrides {
+						target.region, target.label = region, profile+"_"+region
+						targets = append(targets, target)
+					}
+				default:
+					targets = append(targets, target)
+				}
+			}
+			byConfig[path] = selected
+		}
+		if len(profiles) == 0 && cfg.profileName != "" {
+			return Report{}, fmt.Errorf("-profile %q matches no section of the config files", cfg.profileName)
+		}
+	}
+	slog.Info("Selected profiles", "profiles", strings.Join(profiles, ", "))
+	if cfg.compartmentID != "" {
+		scope, err := cfg.compartmentSubtree(ctx, configPath, cfg.compartmentID, cfg.compartmentExact)
+		if err != nil {
+			return Report{}, fmt.Errorf("error listing the compartments below -compartment-id: %w", err)
+		}
+		if scope == nil {
+			slog.Info("-compartment-id is the root compartment, every compartment is in scope")
+		} else {
+			run.compartments = scope
+			slog.Info("Scoping audit to compartments", "compartment", cfg.compartmentID, "compartments", len(scope))
+		}
+	}
+
+	if cfg.labelBy == "region" && cfg.tenanciesFile == "" {
+		// Sections labeled with the same region would write the same files.
+		labeled := make(map[string]string)
+		for _, target := range targets {
+			region := target.region
+			if region == "" {
+				region = string(common.StringToRegion(configs[target.configPath].Section(target.profile).Key("region").String()))
+			}
+			region = target.prefix + region
+			if other, ok := labeled[region]; ok {
+				return Report{}, fmt.Errorf("-label-by region: sections %s and %s both target %s", other, target.name(), region)
+			}
+			labeled[region] = target.name()
+		}
+	}
+	if cfg.skipHomeRegion {
+		if cfg.globalFromHomeOnly {
+			slog.Warn("-global-from-home-only has no effect with -skip-home-region; global resources are kept in every region")
+		}
+	} else {
+		// Each config file may belong to another tenancy.
+		for _, path := range configPaths {
+			logTenancyFailures(run.tenancies.resolve(ctx, path, byConfig[path], cfg.lookupConcurrency))
+		}
+	}
+	if n := run.tenancies.count(); n > 1 {
+		slog.Info("Config sections span several tenancies", "tenancies", n)
+	}
+
+	var rollup *tenancyRollup
+	if cfg.tenanciesFile != "" {
+		byTenancy := make(map[string][]searchTarget)
+		for _, entry := range entries {
+			if tenancyID, err := cfg.profileTenancyID(configPath, entry.profile); err == nil && tenancyID != entry.tenancyID {
+				slog.Warn("Profile belongs to another tenancy than listed", "profile", entry.profile, "tenancy", tenancyID, "listed", entry.tenancyID)
+			}
+			byTenancy[entry.tenancyID] = entry.targets(configPath, defaultConfig.Section(entry.profile).Key("region").String())
+			targets = append(targets, byTenancy[entry.tenancyID]...)
+		}
+		rollup = newTenancyRollup(cfg, entries, byTenancy)
+		run.hooks = chainHooks(run.hooks, Hooks{OnResource: rollup.onResource})
+	}
+
+	if cfg.resolveCompartments {
+		cfg.compartmentNames = newCompartmentNameCache()
+		for _, path := range configPaths {
+			cfg.compartmentNames.load(ctx, run, path, byConfig[path])
+		}
+	}
+
+	if cfg.dedupReportFlag {
+		run.dedup = newDedupReport(columnHeaders(cfg.reportColumns()))
+	}
+	if cfg.combinedReport {
+		run.combined, err = run.startCombinedWriter(columnHeaders(cfg.reportColumns()), cfg.combinedBuffer)
+		if err != nil {
+			return Report{}, fmt.Errorf("error starting combined report: %w", err)
+		}
+	}
+	// The ticker is started last: nothing returns before it is stopped.
+	if cfg.progressInterval > 0 {
+		run.progress = newProgressTracker()
+		run.hooks = chainHooks(run.hooks, Hooks{OnPage: run.progress.onPage})
+		run.progress.start(cfg.progressInterval)
+	}
+
+	var wg sync.WaitGroup
+	summary := newRunSummary()
+	// With -adaptive-concurrency the limiter decides how many regions
+	// search at once, each with one request in flight, so as many regions
+	// as its upper bound are started.
+	workers := cfg.regionConcurrency
+	if run.limiter != nil {
+		workers = cfg.maxConcurrency
+	}
+	sem := make(chan struct{}, workers)
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target searchTarget) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			name := target.name()
+			// Regions still queued when the run is stopped are not scanned.
+			if ctx.Err() != nil {
+				slog.Info("Not started, the run was stopped", "region", name)
+				return
+			}
+			query := settings.queryFor(name)
+			slog.Debug("Processing region", "region", name, "query", query)
+			// The client targets the region key of the profile's config
+			// section unless the target names another region.
+			client, region, err := cfg.newSearchClient(target.configPath, target.profile, target.region)
+			if err != nil {
+				err = fmt.Errorf("error creating client for %s: %w", target.profile, err)
+				slog.Error("Region failed", "region", name, "error", err)
+				run.manifest.addRegionFailure(name, err)
+				return
+			}
+			if len(regionOverrides) > 0 {
+				configured := configs[target.configPath].Section(target.profile).Key("region").String()
+				slog.Info("Region overridden by -region", "region", name, "configured", configured, "effective", region)
+			}
+			tally, err := ExecuteFullSearch(ctx, run, client, region, target, query)
+			if err != nil {
+				slog.Error("Region failed", "region", name, "error", err)
+				run.manifest.addRegionFailure(name, err)
+				return
+			}
+			summary.add(tally)
+		}(target)
+	}
+
+	wg.Wait()
+	if run.progress != nil {
+		run.progress.Stop()
+	}
+
+	switch {
+	case run.isTruncated():
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		run.stopped = fmt.Sprintf("-timeout of %s reached", cfg.timeout)
+	case ctx.Err() != nil:
+		run.stopped = "interrupted"
+	}
+	// scanned may cancel ctx, so it is only called once the reason the scan
+	// stopped has been read from it.
+	if scanned != nil {
+		scanned()
+	}
+	if run.stopped != "" {
+		slog.Warn("Run stopped early, finishing with the partial reports", "reason", run.stopped)
+	}
+
+	if run.ociLog != nil {
+		run.ociLog.Close()
+	}
+	if run.combined != nil {
+		if err := run.combined.Close(); err != nil {
+			slog.Error("Error writing combined report", "error", err)
+		}
+	}
+	if run.dedup != nil {
+		if err := run.dedup.Write(run); err != nil {
+			slog.Error("Error writing deduplicated report", "error", err)
+		}
+	}
+
+	if coverage != nil {
+		if err := coverage.Write(run); err != nil {
+			slog.Error("Error writing tag coverage report", "error", err)
+		}
+	}
+	if namespaces != nil {
+		if err := namespaces.Write(run); err != nil {
+			slog.Error("Error writing namespace usage report", "error", err)
+		}
+	}
+	if costs != nil {
+		if err := costs.Write(run); err != nil {
+			slog.Error("Error writing cost tag coverage report", "error", err)
+		}
+	}
+	if retired != nil {
+		if err := retired.Write(run); err != nil {
+			slog.Error("Error writing retired namespace report", "error", err)
+		}
+	}
+	if err := summary.Write(run); err != nil {
+		slog.Error("Error writing summary", "error", err)
+	}
+	if cfg.compartmentCompliance {
+		if err := summary.WriteCompartmentCompliance(run); err != nil {
+			slog.Error("Error writing compartment compliance report", "error", err)
+		}
+	}
+	if run.workbook != nil {
+		if err := run.workbook.Write(run); err != nil {
+			slog.Error("Error writing workbook", "error", err)
+		}
+	}
+	if cfg.writeMetrics {
+		if err := summary.WriteMetrics(run); err != nil {
+			slog.Error("Error writing metrics", "error", err)
+		}
+	}
+	if cfg.webhookURL != "" {
+		// The run may have been stopped, but the summary is still sent.
+		if err := summary.sendWebhook(context.Background(), run, cfg.webhookURL, cfg.webhookAlways); err != nil {
+			slog.Error("Error sending webhook", "error", err)
+		}
+	}
+	if rollup != nil {
+		if err := rollup.Write(run, entries); err != nil {
+			slog.Error("Error writing tenancy roll-up", "error", err)
+		}
+	}
+	if environments != nil {
+		if err := environments.Write(run); err != nil {
+			slog.Error("Error writing environment summary", "error", err)
+		}
+	}
+	if score != nil {
+		if err := score.Write(run); err != nil {
+			slog.Error("Error writing compliance score report", "error", err)
+		}
+	}
+	if inventory != nil {
+		if err := inventory.Write(run); err != nil {
+			slog.Error("Error writing resource type inventory", "error", err)
+		}
+	}
+
+	if cfg.postHook != "" {
+		run.runPostHooks(cfg.postHook, cfg.postHookConcurrency)
+	}
+
+	// Every report is closed by now, the combined report included, so the
+	// digests cover the final content. They are taken before archiving so
+	// the sidecars go into the archive.
+	if cfg.checksums {
+		if err := run.checksumPending(); err != nil {
+			slog.Error("Error writing checksums", "error", err)
+		}
+	}
+
+	if cfg.archiveOutput {
+		if path, err := run.archiveOutputs(); err != nil {
+			slog.Error("Error archiving output", "error", err)
+		} else {
+			slog.Info("Archived output", "path", path)
+			if cfg.checksums {
+				// The archive sidecar is not an archived file, so it is not
+				// recorded in Files and survives -archive-cleanup.
+				if _, err := run.checksum(path); err != nil {
+					slog.Error("Error writing archive checksum", "error", err)
+				}
+			}
+			if cfg.archiveCleanup {
+				run.removeArchivedFiles()
+			}
+		}
+	}
+
+	if err := run.finish(); err != nil {
+		slog.Error("Error writing run manifest", "error", err)
+	}
+	uploadFailures := 0
+	if cfg.uploadBucket != "" {
+		// ctx may already be cancelled by -timeout or a signal; the partial
+		// reports are uploaded regardless.
+		uploadCtx := context.Background()
+		if u, err := cfg.newUploader(uploadCtx, configPath, cfg.uploadNamespace, cfg.uploadBucket, cfg.uploadPartSize); err != nil {
+			slog.Error("Error uploading to bucket", "bucket", cfg.uploadBucket, "error", err)
+			uploadFailures++
+		} else {
+			uploadFailures = run.uploadRun(uploadCtx, u, run.outputPath(fmt.Sprintf("manifest_%s.json", run.timestamp)))
+		}
+	}
+	if cfg.debugOCID != "" && !run.debugSeen() {
+		slog.Warn("-debug-ocid was not found in any region", "ocid", cfg.debugOCID)
+	}
+	report := run.report(summary)
+	if failed, errs := run.manifest.regionFailures(); len(failed) > 0 {
+		for _, region := range failed {
+			slog.Error("Failed region", "region", region, "error", errs[region])
+		}
+		return report, fmt.Errorf("%d of %d regions failed", len(failed), len(targets))
+	}
+	if run.isTruncated() {
+		slog.Warn("Run truncated: -max-total reached, reports are partial", "max_total", cfg.maxTotal)
+		return report, nil
+	}
+	if run.stopped != "" {
+		return report, fmt.Errorf("run stopped early, reports are partial: %s", run.stopped)
+	}
+	if uploadFailures > 0 {
+		return report, fmt.Errorf("upload to bucket %s failed for %d files", cfg.uploadBucket, uploadFailures)
+	}
+	slog.Info("All regions processed successfully")
+	return report, nil
+}
+
+//
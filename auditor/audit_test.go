@@ -0,0 +1,367 @@
+package auditor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/resourcesearch"
+)
+
+// staticSearch is a SearchClient returning one page of fixed resources.
+type staticSearch []ResourceSummary
+
+func (s staticSearch) SearchResources(context.Context, resourcesearch.SearchResourcesRequest) (resourcesearch.SearchResourcesResponse, error) {
+	var response resourcesearch.SearchResourcesResponse
+	response.Items = s
+	return response, nil
+}
+
+// badTagValue is a defined tag value the JSON encoder cannot write.
+type badTagValue struct{}
+
+func (badTagValue) MarshalJSON() ([]byte, error) {
+	panic("unexpected tag value")
+}
+
+// readReport returns the records of the only file matching pattern in dir.
+func readReport(t *testing.T, dir, pattern string) [][]string {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil || len(paths) != 1 {
+		t.Fatalf("files matching %s: %v, %v", pattern, paths, err)
+	}
+	file, err := os.Open(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestMalformedResourceSkipped(t *testing.T) {
+	bad := testResource("ocid1.instance.bad", compliantTags)
+	bad.DefinedTags["Ops"]["CostCenter"] = badTagValue{}
+	client := staticSearch{
+		testResource("ocid1.instance.a", compliantTags),
+		bad,
+		testResource("ocid1.instance.b", violatingTags),
+	}
+
+	dir := t.TempDir()
+	cfg := testConfig(t, "-output-dir", dir)
+	var hooked []string
+	cfg.userHooks.OnResource = func(_ string, r ResourceSummary) {
+		hooked = append(hooked, getStringValue(r.Identifier))
+	}
+	run := newAuditRun(cfg, func() {})
+
+	tally, err := ExecuteFullSearch(context.Background(), run, client, "us-ashburn-1", searchTarget{profile: "DEFAULT"}, "query all resources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tally.counts.resources != 2 {
+		t.Errorf("%d resources counted, want 2", tally.counts.resources)
+	}
+	records := readReport(t, dir, "DEFAULT_resources_*.csv")
+	if len(records) != 3 || records[1][4] != "ocid1.instance.a" || records[2][4] != "ocid1.instance.b" {
+		t.Errorf("main report rows = %v, want a and b", records[1:])
+	}
+	if len(hooked) != 2 {
+		t.Errorf("hooks saw %v, want a and b", hooked)
+	}
+}
+
+func TestMaxTotalCountsWrittenResources(t *testing.T) {
+	bad := testResource("ocid1.instance.bad", compliantTags)
+	bad.DefinedTags["Ops"]["CostCenter"] = badTagValue{}
+	client := staticSearch{
+		bad,
+		testResource("ocid1.instance.a", compliantTags),
+		testResource("ocid1.instance.b", compliantTags),
+		testResource("ocid1.instance.c", compliantTags),
+	}
+	cfg := testConfig(t, "-output-dir", t.TempDir(), "-max-total", "2")
+	run := newAuditRun(cfg, func() {})
+
+	tally, err := ExecuteFullSearch(context.Background(), run, client, "us-ashburn-1", searchTarget{profile: "DEFAULT"}, "query all resources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The skipped resource does not use up the budget.
+	if tally.counts.resources != 2 || !run.isTruncated() {
+		t.Errorf("%d resources, truncated %v, want 2 and truncated", tally.counts.resources, run.isTruncated())
+	}
+
+	// A slot given back by a failed write can be claimed again.
+	run = newAuditRun(testConfig(t, "-output-dir", t.TempDir(), "-max-total", "1"), func() {})
+	if !run.reserve() {
+		t.Fatal("first reserve refused")
+	}
+	run.unreserve()
+	if !run.reserve() || run.reserve() {
+		t.Error("the returned slot was not claimed exactly once")
+	}
+}
+
+func TestHookPanicNotSwallowed(t *testing.T) {
+	cfg := testConfig(t, "-output-dir", t.TempDir())
+	cfg.userHooks.OnResource = func(string, ResourceSummary) { panic("hook bug") }
+	run := newAuditRun(cfg, func() {})
+
+	defer func() {
+		if p := recover(); p != "hook bug" {
+			t.Errorf("recovered %v, want the hook's panic", p)
+		}
+	}()
+	ExecuteFullSearch(context.Background(), run, staticSearch{testResource("ocid1.instance.a", compliantTags)}, "us-ashburn-1", searchTarget{profile: "DEFAULT"}, "query all resources")
+	t.Error("ExecuteFullSearch returned after a hook panicked")
+}
+
+func TestFlattenDefinedTags(t *testing.T) {
+	tests := []struct {
+		name        string
+		definedTags map[string]map[string]interface{}
+		want        string
+	}{
+		{"none", nil, ""},
+		{"empty namespace", map[string]map[string]interface{}{"Ops": {}}, ""},
+		{
+			"sorted across namespaces and keys",
+			map[string]map[string]interface{}{
+				"Zeta":        {"b": "2", "a": "1"},
+				"Ops":         {"Project": "web", "CostCenter": 42},
+				"Oracle-Tags": {"CreatedBy": "alice"},
+			},
+			"Ops.CostCenter=42; Ops.Project=web; Oracle-Tags.CreatedBy=alice; Zeta.a=1; Zeta.b=2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Map iteration order varies, so flatten repeatedly.
+			for i := 0; i < 20; i++ {
+				if got := FlattenDefinedTags(tt.definedTags); got != tt.want {
+					t.Fatalf("FlattenDefinedTags = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFreeformTagsToString(t *testing.T) {
+	tests := []struct {
+		name string
+		tags map[string]string
+		want string
+	}{
+		{"none", nil, ""},
+		{"plain", map[string]string{"team": "web", "env": "prod"}, "env=prod, team=web"},
+		{"comma in value", map[string]string{"owners": "alice, bob"}, `owners=alice\, bob`},
+		{"equals in value", map[string]string{"query": "a=b"}, `query=a\=b`},
+		{"separators in key", map[string]string{"a=b,c": "x"}, `a\=b\,c=x`},
+		{"backslash", map[string]string{"path": `C:\tmp`}, `path=C:\\tmp`},
+		{"empty value", map[string]string{"empty": "", "team": "web"}, "empty=, team=web"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FreeformTagsToString(tt.tags)
+			if got != tt.want {
+				t.Errorf("FreeformTagsToString = %q, want %q", got, tt.want)
+			}
+			if parsed := parseFreeformTags(got); len(tt.tags) > 0 && !reflect.DeepEqual(parsed, tt.tags) {
+				t.Errorf("parsed back as %q, want %q", parsed, tt.tags)
+			}
+		})
+	}
+}
+
+// cannedSearch is a SearchClient returning canned pages in order. Page
+// tokens are page indexes and errors are returned instead of the page at
+// their index.
+type cannedSearch struct {
+	pages  [][]ResourceSummary
+	errors map[int]error
+}
+
+func (c *cannedSearch) SearchResources(_ context.Context, request resourcesearch.SearchResourcesRequest) (resourcesearch.SearchResourcesResponse, error) {
+	page := 0
+	if request.Page != nil {
+		page, _ = strconv.Atoi(*request.Page)
+	}
+	var response resourcesearch.SearchResourcesResponse
+	if err := c.errors[page]; err != nil {
+		return response, err
+	}
+	response.Items = c.pages[page]
+	if page+1 < len(c.pages) {
+		response.OpcNextPage = common.String(strconv.Itoa(page + 1))
+	}
+	return response, nil
+}
+
+func TestExecuteFullSearchFakeClient(t *testing.T) {
+	noOwner := testResource("ocid1.instance.noowner", `{"Ops":{"CostCenter":"42"}}`)
+	untagged := testResource("ocid1.instance.untagged", `{}`)
+	client := &cannedSearch{pages: [][]ResourceSummary{
+		{testResource("ocid1.instance.ok", compliantTags), noOwner},
+		{},
+		{testResource("ocid1.instance.missing", violatingTags), untagged},
+	}}
+
+	dir := t.TempDir()
+	cfg := testConfig(t, "-output-dir", dir, "-missing-tags", "-no-owner", "-required-tags", "Ops.CostCenter", "-rate", "0")
+	var pages []int
+	cfg.userHooks.OnPage = func(_ string, items int) { pages = append(pages, items) }
+	run := newAuditRun(cfg, func() {})
+
+	tally, err := ExecuteFullSearch(context.Background(), run, client, "us-ashburn-1", searchTarget{profile: "DEFAULT"}, "query all resources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pages, []int{2, 0, 2}) {
+		t.Errorf("pages = %v, want 2, 0 and 2 resources", pages)
+	}
+	if got := tally.counts; got != (tallyCounts{resources: 4, missingTags: 2, noOwner: 2}) {
+		t.Errorf("counts = %+v", got)
+	}
+
+	identifiers := func(pattern string) []string {
+		var ids []string
+		for _, record := range readReport(t, dir, pattern)[1:] {
+			ids = append(ids, record[4])
+		}
+		return ids
+	}
+	if got := identifiers("DEFAULT_resources_*.csv"); len(got) != 4 {
+		t.Errorf("main report lists %v", got)
+	}
+	if got := identifiers("DEFAULT_missing_tags_*.csv"); !reflect.DeepEqual(got, []string{"ocid1.instance.missing", "ocid1.instance.untagged"}) {
+		t.Errorf("missing tags report lists %v", got)
+	}
+	if got := identifiers("DEFAULT_no_owner_*.csv"); !reflect.DeepEqual(got, []string{"ocid1.instance.noowner", "ocid1.instance.untagged"}) {
+		t.Errorf("no owner report lists %v", got)
+	}
+}
+
+func TestExecuteFullSearchFailedPage(t *testing.T) {
+	client := &cannedSearch{
+		pages:  [][]ResourceSummary{{testResource("ocid1.instance.ok", compliantTags)}, nil},
+		errors: map[int]error{1: errors.New("search failed")},
+	}
+	cfg := testConfig(t, "-output-dir", t.TempDir(), "-rate", "0", "-max-retries", "0")
+	run := newAuditRun(cfg, func() {})
+
+	_, err := ExecuteFullSearch(context.Background(), run, client, "us-ashburn-1", searchTarget{profile: "DEFAULT"}, "query all resources")
+	if err == nil || !strings.Contains(err.Error(), "search failed") {
+		t.Errorf("error = %v, want the failed page's", err)
+	}
+}
+
+// failingWriter fails every write, like a full disk.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("no space left on device")
+}
+
+// jsonlReport is a JSON Lines report writing to w without buffering
+// whole rows, so a failing writer fails the row's Write.
+func jsonlReport(w io.Writer, headers []string) *reportFile {
+	return &reportFile{format: formatJSONL, headers: headers, out: bufio.NewWriterSize(w, 16)}
+}
+
+func TestWriteFailureKeepsCountsConsistent(t *testing.T) {
+	cfg := testConfig(t, "-output-dir", t.TempDir(), "-missing-tags", "-no-owner", "-required-tags", "Ops.CostCenter")
+	report := newRegionReport(newAuditRun(cfg, func() {}), "DEFAULT")
+	var missing, noOwner bytes.Buffer
+	report.missingTags = jsonlReport(&missing, report.headers)
+	report.noOwner = jsonlReport(&noOwner, report.headers)
+
+	resources := []struct {
+		resource ResourceSummary
+		fails    bool
+	}{
+		{testResource("ocid1.instance.ok", compliantTags), false},
+		{testResource("ocid1.instance.lost", `{}`), true},
+		{testResource("ocid1.instance.missing", violatingTags), false},
+	}
+	var hooked int
+	for _, r := range resources {
+		var main bytes.Buffer
+		report.main = jsonlReport(&main, report.headers)
+		if r.fails {
+			report.main = jsonlReport(failingWriter{}, report.headers)
+		}
+		written := report.writeResource("DEFAULT", r.resource, buildRow(report.columns, "DEFAULT", r.resource))
+		if written == r.fails {
+			t.Errorf("%s: written %v", getStringValue(r.resource.Identifier), written)
+		}
+		if written {
+			hooked++
+		}
+	}
+
+	// The lost resource is in no report and no count.
+	if report.totalResources != 2 || report.writeErrors != 1 || hooked != 2 {
+		t.Errorf("%d resources, %d write errors, %d hooked", report.totalResources, report.writeErrors, hooked)
+	}
+	if report.missingTagsCount != 1 || report.missingTags.rows != 1 || report.noOwnerCount != 0 || report.noOwner.rows != 0 {
+		t.Errorf("missing tags %d in %d rows, no owner %d in %d rows", report.missingTagsCount, report.missingTags.rows, report.noOwnerCount, report.noOwner.rows)
+	}
+	if got := report.tally.counts; got != (tallyCounts{resources: 2, missingTags: 1}) {
+		t.Errorf("tally = %+v", got)
+	}
+	summary := report.summary(0)
+	if summary["processed"] != 2 || summary["write_errors"] != 1 || summary["missing_tags"] != 1 || summary["no_owner"] != 0 {
+		t.Errorf("summary = %v", summary)
+	}
+}
+
+func TestExecuteRejectsFlagsFirst(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"concurrency", []string{"-concurrency", "0"}, "-concurrency must be at least 1"},
+		{"lookup concurrency", []string{"-lookup-concurrency", "0"}, "-lookup-concurrency must be at least 1"},
+		{"label", []string{"-label-by", "tenancy"}, "-label-by must be"},
+		{"region with tenancies file", []string{"-region", "us-ashburn-1", "-tenancies-file", "tenancies.csv"}, "-region cannot be used with -tenancies-file"},
+		{"metrics file", []string{"-metrics-file", "audit.prom"}, "-metrics-file requires -metrics"},
+		{"webhook always", []string{"-webhook-always"}, "-webhook-always requires -webhook-url"},
+		{"webhook scheme", []string{"-webhook-url", "hooks.example.com/audit"}, "-webhook-url must be an http or https URL"},
+		{"exceptions only", []string{"-exceptions-only"}, "-exceptions-only writes only the -missing-tags and -no-owner reports"},
+		{"progress", []string{"-progress", "-1s"}, "-progress must not be negative"},
+		{"combined buffer", []string{"-progress", "1s", "-combined", "-combined-buffer", "0"}, "-combined-buffer must be at least 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The config file does not exist, so anything past the flag
+			// checks would fail with another error.
+			dir := t.TempDir()
+			output := filepath.Join(dir, "out")
+			cfg := testConfig(t, append([]string{"-output-dir", output, "-config", filepath.Join(dir, "config")}, tt.args...)...)
+			_, err := cfg.execute(context.Background(), "", nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want one containing %q", err, tt.want)
+			}
+			if _, err := os.Stat(output); !os.IsNotExist(err) {
+				t.Errorf("-output-dir was created: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+// Package auditor scans OCI tenancies with the resource search API and
+// reports resources with missing or invalid tags. The oci-tag-auditor
+// command is a thin wrapper around Main; other Go programs run audits with
+// an Auditor.
+package auditor
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// Auditor runs audits from a Go program. The fields are the most used
+// options; Args sets any other option with the command-line flags, e.g.
+// []string{"-check-retired-namespaces", "-format", "jsonl"}. Zero fields
+// keep the flag defaults.
+//
+// Every Run has options of its own, so audits may run concurrently, from
+// one Auditor or several. Runs writing to the same OutputDir in the same
+// second would write the same files.
+type Auditor struct {
+	// ConfigFile is the OCI config file, or a comma-separated list (-config).
+	ConfigFile string
+	// Profile audits only this config section (-profile).
+	Profile string
+	// Query is the structured search query (-query).
+	Query string
+	// OutputDir is the directory reports are written to (-output-dir).
+	OutputDir string
+	// OutputFormat is csv, json, jsonl or xlsx (-format).
+	OutputFormat string
+	// RequiredTags are Namespace.Key defined tags every resource must carry
+	// (-required-tags).
+	RequiredTags []string
+	// MissingTags and NoOwner write the missing tags and no owner reports
+	// (-missing-tags, -no-owner).
+	MissingTags bool
+	NoOwner     bool
+	// DryRun scans without writing any files (-dry-run).
+	DryRun bool
+
+	// Args are further command-line flags, applied after the fields.
+	Args []string
+	// Hooks are called after the built-in reports for every resource and
+	// page.
+	Hooks Hooks
+}
+
+// Report is the outcome of a run.
+type Report struct {
+	// Manifest lists the files written, the regions that failed and
+	// whether the run was truncated. It is nil when the run ended before
+	// the scan, as with an invalid option.
+	Manifest *Manifest
+	// Regions has the counts of every region scanned, in name order.
+	Regions []RegionCounts
+	// Stopped tells why the run stopped early, e.g. "interrupted"; it is
+	// empty for a complete run.
+	Stopped string
+}
+
+// RegionCounts are the resources of a region, and those missing tags or an
+// owner.
+type RegionCounts struct {
+	Region      string `json:"region"`
+	Resources   int    `json:"resources"`
+	MissingTags int    `json:"missing_tags"`
+	NoOwner     int    `json:"no_owner"`
+}
+
+// Run audits with the Auditor's options. Cancelling ctx stops the scan like
+// -timeout does: the reports written so far are finished and Run returns
+// with Report.Stopped set. The error is non-nil for invalid options and
+// for runs that failed, stopped early or could not upload their files; the
+// Report is filled in whenever the scan ran.
+func (a *Auditor) Run(ctx context.Context) (Report, error) {
+	cfg, fs := newConfig("oci-tag-auditor", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	for _, option := range a.flagValues() {
+		if err := fs.Set(option[0], option[1]); err != nil {
+			return Report{}, fmt.Errorf("invalid -%s: %w", option[0], err)
+		}
+	}
+	if err := fs.Parse(a.Args); err != nil {
+		return Report{}, fmt.Errorf("invalid Args: %w", err)
+	}
+	if fs.NArg() > 0 {
+		return Report{}, fmt.Errorf("unexpected argument %q in Args, only flags are accepted", fs.Arg(0))
+	}
+
+	cfg.userHooks = a.Hooks
+	return cfg.execute(ctx, "", nil)
+}
+
+// flagValues returns the flags set by the Auditor's non-zero fields.
+func (a *Auditor) flagValues() [][2]string {
+	var options [][2]string
+	add := func(name, value string) {
+		if value != "" {
+			options = append(options, [2]string{name, value})
+		}
+	}
+	add("config", a.ConfigFile)
+	add("profile", a.Profile)
+	add("query", a.Query)
+	add("output-dir", a.OutputDir)
+	add("format", a.OutputFormat)
+	add("required-tags", strings.Join(a.RequiredTags, ","))
+	if a.MissingTags {
+		add("missing-tags", "true")
+	}
+	if a.NoOwner {
+		add("no-owner", "true")
+	}
+	if a.DryRun {
+		add("dry-run", "true")
+	}
+	return options
+}
+
+// newConfig returns a config holding the flag defaults, and the flag set
+// that sets its options.
+func newConfig(name string, errorHandling flag.ErrorHandling) (*config, *flag.FlagSet) {
+	cfg := &config{}
+	fs := flag.NewFlagSet(name, errorHandling)
+	cfg.registerFlags(fs)
+	return cfg, fs
+}
+
+// Main runs the oci-tag-auditor command with the command-line arguments,
+// without the program name: the flags, then an optional subcommand and its
+// arguments. It returns the exit status.
+func Main(args []string) int {
+	cfg, fs := newConfig(os.Args[0], flag.ExitOnError)
+	// ExitOnError exits on a parse error instead of returning it.
+	_ = fs.Parse(args)
+	args = fs.Args()
+
+	if err := cfg.setupLogging(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	var command string
+	if len(args) > 0 {
+		command = args[0]
+	}
+	switch command {
+	case "", "tenancy-info":
+	case "validate":
+		return cfg.runValidate(args[1:])
+	case "report-changes":
+		if err := cfg.loadChecks(); err != nil {
+			slog.Error(err.Error())
+			return 1
+		}
+		return cfg.runReportChanges(args[1:])
+	default:
+		slog.Error(fmt.Sprintf("Unknown subcommand %q", command))
+		return 1
+	}
+
+	// An interrupt stops the scan like -timeout does. A second interrupt
+	// while the run is being finished exits at once.
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	if _, err := cfg.execute(ctx, command, stopSignals); err != nil {
+		slog.Error(err.Error())
+		return 1
+	}
+	return 0
+}
+
+// report returns the outcome of the run; summary is nil for runs that did
+// not scan regions, such as -resource-ocid lookups.
+func (run *auditRun) report(summary *runSummary) Report {
+	report := Report{Manifest: run.manifest, Stopped: run.stopped}
+	if summary != nil {
+		report.Regions = summary.regionCounts()
+	}
+	return report
+}
@@ -0,0 +1,62 @@
+package auditor
+
+import (
+	"fmt"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/common/auth"
+	"gopkg.in/ini.v1"
+)
+
+// Authentication modes of the -auth flag.
+const (
+	authConfig   = "config"
+	authInstance = "instance"
+	authResource = "resource"
+)
+
+// newConfigProvider returns the configuration provider of a config profile.
+// With -auth instance or resource there is no config file: every profile
+// uses the same principal, which is created once.
+func (cfg *config) newConfigProvider(configPath, profile string) (common.ConfigurationProvider, error) {
+	if cfg.authMode == authConfig {
+		return common.ConfigurationProviderFromFileWithProfile(configPath, profile, "")
+	}
+
+	cfg.principalOnce.Do(func() {
+		if cfg.authMode == authInstance {
+			cfg.principalProvider, cfg.principalErr = auth.InstancePrincipalConfigurationProvider()
+		} else {
+			cfg.principalProvider, cfg.principalErr = auth.ResourcePrincipalConfigurationProvider()
+		}
+		if cfg.principalErr != nil {
+			cfg.principalErr = fmt.Errorf("%s principal: %w", cfg.authMode, cfg.principalErr)
+		}
+	})
+	return cfg.principalProvider, cfg.principalErr
+}
+
+// principalConfig stands in for the config file with -auth instance or
+// resource: a DEFAULT section and one section named after the principal's
+// region, so the principal's region is scanned like a config section.
+func (cfg *config) principalConfig() (*ini.File, error) {
+	provider, err := cfg.newConfigProvider("", "DEFAULT")
+	if err != nil {
+		return nil, err
+	}
+	region, err := provider.Region()
+	if err != nil {
+		return nil, fmt.Errorf("error reading the principal's region: %w", err)
+	}
+	region = string(common.StringToRegion(region))
+
+	file := ini.Empty()
+	section, err := file.NewSection(region)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := section.NewKey("region", region); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
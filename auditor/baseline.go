@@ -0,0 +1,137 @@
+package auditor
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// baselineCompliance records which resources were non-compliant in a
+// baseline report, keyed by OCID.
+type baselineCompliance struct {
+	path      string
+	violating map[string]bool
+}
+
+// loadBaseline reads a main or combined report from an earlier run and
+// re-evaluates each row against the current checks, so a change of rules
+// does not show up as a change of the resources. Rows of in-flight
+// resources, and of resources that were in their grace period then, are
+// skipped because they are not checked for compliance, as are rows whose
+// tags were cut by -max-cell-length.
+func (cfg *config) loadBaseline(path string) (*baselineCompliance, error) {
+	file, err := openReportReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening baseline report: %w", err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading baseline report %s: %w", path, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("baseline report %s is empty", path)
+	}
+
+	index := make(map[string]int, len(records[0]))
+	for i, h := range records[0] {
+		index[h] = i
+	}
+	for _, required := range []string{"Identifier", "Defined Tags", "Freeform Tags"} {
+		if _, ok := index[required]; !ok {
+			return nil, fmt.Errorf("baseline report %s has no %q column", path, required)
+		}
+	}
+
+	baseline := &baselineCompliance{path: path, violating: make(map[string]bool, len(records)-1)}
+	for i, record := range records[1:] {
+		checked, violating, err := cfg.rowViolation(index, record)
+		if err != nil {
+			return nil, fmt.Errorf("baseline report %s line %d: %w", path, i+2, err)
+		}
+		if checked {
+			baseline.violating[record[index["Identifier"]]] = violating
+		}
+	}
+	return baseline, nil
+}
+
+// rowViolation re-evaluates a main report row against the current checks.
+// It reports whether the row can be checked, and if so whether it is
+// non-compliant. index maps the report's headers to their columns and must
+// include "Defined Tags" and "Freeform Tags".
+func (cfg *config) rowViolation(index map[string]int, record []string) (bool, bool, error) {
+	if column, ok := index["Lifecycle State"]; ok && cfg.transitionalStates[strings.ToUpper(record[column])] {
+		return false, false, nil
+	}
+	if column, ok := index["Days Since Creation"]; ok {
+		if days, err := strconv.Atoi(record[column]); err == nil && days < cfg.graceDays {
+			return false, false, nil
+		}
+	}
+	if isTruncatedCell(record[index["Defined Tags"]]) || isTruncatedCell(record[index["Freeform Tags"]]) {
+		return false, false, nil
+	}
+
+	r := ResourceSummary{FreeformTags: parseFreeformTags(record[index["Freeform Tags"]])}
+	if definedTags := record[index["Defined Tags"]]; definedTags != "" {
+		if err := json.Unmarshal([]byte(definedTags), &r.DefinedTags); err != nil {
+			return false, false, fmt.Errorf("invalid defined tags: %w", err)
+		}
+	}
+	return true, len(cfg.complianceReasons(r)) > 0, nil
+}
+
+// parseFreeformTags reverses FreeformTagsToString, unescaping keys and
+// values. In reports written before separators were escaped, values that
+// contain ", " cannot be told apart from separators and are split.
+func parseFreeformTags(s string) map[string]string {
+	if s == "" {
+		return nil
+	}
+	tags := make(map[string]string)
+	var key, value strings.Builder
+	current, hasValue := &key, false
+	flush := func() {
+		if hasValue {
+			tags[key.String()] = value.String()
+		}
+		key.Reset()
+		value.Reset()
+		current, hasValue = &key, false
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i++
+			current.WriteByte(s[i])
+		case c == '=' && !hasValue:
+			current, hasValue = &value, true
+		case c == ',' && i+1 < len(s) && s[i+1] == ' ':
+			i++
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	return tags
+}
+
+// statusChange compares a resource's compliance with the baseline. A
+// resource missing from the baseline is treated as previously compliant.
+func (b *baselineCompliance) statusChange(ocid string, violating bool) string {
+	wasViolating := b.violating[ocid]
+	switch {
+	case violating && wasViolating:
+		return "still-violating"
+	case violating:
+		return "new-violation"
+	case wasViolating:
+		return "remediated"
+	}
+	return "unchanged"
+}
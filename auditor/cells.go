@@ -0,0 +1,34 @@
+package auditor
+
+import "strings"
+
+// truncatedMarker ends every cell shortened by -max-cell-length.
+const truncatedMarker = "…(truncated)"
+
+// truncateCells shortens, in place, the cells of row longer than limit
+// characters to limit characters plus truncatedMarker. It returns the full
+// values of the shortened cells by column index.
+func truncateCells(row []string, limit int) map[int]string {
+	var full map[int]string
+	for i, cell := range row {
+		if len(cell) <= limit {
+			continue
+		}
+		runes := []rune(cell)
+		if len(runes) <= limit {
+			continue
+		}
+		if full == nil {
+			full = make(map[int]string)
+		}
+		full[i] = cell
+		row[i] = string(runes[:limit]) + truncatedMarker
+	}
+	return full
+}
+
+// isTruncatedCell reports whether a cell read back from a report was shortened
+// by -max-cell-length, so its value is incomplete.
+func isTruncatedCell(cell string) bool {
+	return strings.HasSuffix(cell, truncatedMarker)
+}
@@ -0,0 +1,251 @@
+package auditor
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// snapshotResource is one resource of a past run, as needed for a changelog.
+type snapshotResource struct {
+	region       string
+	name         string
+	resourceType string
+	// checked is false for resources that were in flight, in their grace
+	// period or had truncated tags; their compliance is unknown.
+	checked   bool
+	violating bool
+}
+
+// runSnapshot is the resources of a past run, keyed by OCID, read back
+// from the main reports its manifest lists.
+type runSnapshot struct {
+	cfg       *config
+	id        string
+	resources map[string]snapshotResource
+}
+
+// runHistory returns the manifests of past runs keyed by run ID, and the
+// IDs oldest first. With -prefix-tenancy and -tenancy-name only the runs of
+// that tenancy are read; otherwise manifests with any file name prefix are.
+func (cfg *config) runHistory() (map[string]string, []string, error) {
+	run := newAuditRun(cfg, nil)
+	pattern := "*manifest_*.json"
+	if cfg.prefixTenancy && cfg.tenancyName != "" {
+		run.filePrefix = fileNameSafe(cfg.tenancyName) + "_"
+		pattern = "manifest_*.json"
+	}
+	paths, err := run.historyGlob(pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifests := make(map[string]string, len(paths))
+	ids := make([]string, 0, len(paths))
+	for _, path := range paths {
+		base := filepath.Base(path)
+		id := strings.TrimSuffix(base[strings.LastIndex(base, "manifest_")+len("manifest_"):], ".json")
+		if _, ok := manifests[id]; !ok {
+			ids = append(ids, id)
+		}
+		manifests[id] = path
+	}
+	// Prefixes sort before timestamps, so order by the IDs themselves.
+	sort.Strings(ids)
+	return manifests, ids, nil
+}
+
+// loadRunSnapshot reads the main reports listed in a run's manifest. The
+// combined report repeats the per-region rows and is skipped.
+func (cfg *config) loadRunSnapshot(id, manifestPath string) (*runSnapshot, error) {
+	bytes, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", manifestPath, err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(bytes, &manifest); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", manifestPath, err)
+	}
+
+	snapshot := &runSnapshot{cfg: cfg, id: id, resources: make(map[string]snapshotResource)}
+	suffix := "_resources_" + id + ".csv"
+	for _, path := range manifest.Files {
+		base := strings.TrimSuffix(filepath.Base(path), ".gz")
+		if !strings.HasSuffix(base, suffix) || strings.HasSuffix(base, "all_regions"+suffix) {
+			continue
+		}
+		if err := snapshot.addReport(path); err != nil {
+			return nil, err
+		}
+	}
+	return snapshot, nil
+}
+
+func (s *runSnapshot) addReport(path string) error {
+	file, err := openReportReader(path)
+	if err != nil {
+		return fmt.Errorf("error opening report: %w", err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return fmt.Errorf("error reading report %s: %w", path, err)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	index := make(map[string]int, len(records[0]))
+	for i, h := range records[0] {
+		index[h] = i
+	}
+	addLegacyHeaders(index)
+	for _, required := range []string{"Profile", "Identifier", "Defined Tags", "Freeform Tags"} {
+		if _, ok := index[required]; !ok {
+			return fmt.Errorf("report %s has no %q column", path, required)
+		}
+	}
+	field := func(record []string, header string) string {
+		if i, ok := index[header]; ok {
+			return record[i]
+		}
+		return ""
+	}
+
+	for i, record := range records[1:] {
+		checked, violating, err := s.cfg.rowViolation(index, record)
+		if err != nil {
+			return fmt.Errorf("report %s line %d: %w", path, i+2, err)
+		}
+		s.resources[record[index["Identifier"]]] = snapshotResource{
+			region:       record[index["Profile"]],
+			name:         field(record, "Display Name"),
+			resourceType: field(record, "Resource Type"),
+			checked:      checked,
+			violating:    violating,
+		}
+	}
+	return nil
+}
+
+// runChanges is the changelog between two runs.
+type runChanges struct {
+	from, to        string
+	remediated      []string
+	newlyViolating  []string
+	added           []string
+	addedViolating  int
+	removed         []string
+	resourcesBefore int
+	resourcesAfter  int
+}
+
+// compareRuns compares the resources of two runs by OCID. Compliance only
+// changes between two checked states; a resource whose compliance was
+// unknown in either run is not counted as remediated or newly violating.
+func compareRuns(before, after *runSnapshot) *runChanges {
+	c := &runChanges{from: before.id, to: after.id, resourcesBefore: len(before.resources), resourcesAfter: len(after.resources)}
+	for ocid, now := range after.resources {
+		was, existed := before.resources[ocid]
+		switch {
+		case !existed:
+			c.added = append(c.added, ocid)
+			if now.checked && now.violating {
+				c.addedViolating++
+			}
+		case !was.checked || !now.checked:
+		case was.violating && !now.violating:
+			c.remediated = append(c.remediated, ocid)
+		case !was.violating && now.violating:
+			c.newlyViolating = append(c.newlyViolating, ocid)
+		}
+	}
+	for ocid := range before.resources {
+		if _, ok := after.resources[ocid]; !ok {
+			c.removed = append(c.removed, ocid)
+		}
+	}
+	for _, list := range [][]string{c.remediated, c.newlyViolating, c.added, c.removed} {
+		sort.Strings(list)
+	}
+	return c
+}
+
+// write prints the changelog as plain text or Markdown. Newly non-compliant
+// resources are listed; the other changes are counted.
+func (c *runChanges) write(w io.Writer, after *runSnapshot, markdown bool) {
+	heading, bullet := "Changes from run %s to run %s\n\n", "- "
+	if markdown {
+		heading = "## Changes from run `%s` to run `%s`\n\n"
+	}
+	fmt.Fprintf(w, heading, c.from, c.to)
+	fmt.Fprintf(w, "%s%d resources became compliant\n", bullet, len(c.remediated))
+	fmt.Fprintf(w, "%s%d resources became non-compliant\n", bullet, len(c.newlyViolating))
+	fmt.Fprintf(w, "%s%d new resources, %d of them non-compliant\n", bullet, len(c.added), c.addedViolating)
+	fmt.Fprintf(w, "%s%d resources removed\n", bullet, len(c.removed))
+	fmt.Fprintf(w, "%s%d resources before, %d after\n", bullet, c.resourcesBefore, c.resourcesAfter)
+
+	if len(c.newlyViolating) == 0 {
+		return
+	}
+	if markdown {
+		fmt.Fprintf(w, "\n### Newly non-compliant\n\n| Region | Display Name | Resource Type | Identifier |\n|---|---|---|---|\n")
+	} else {
+		fmt.Fprintf(w, "\nNewly non-compliant:\n")
+	}
+	for _, ocid := range c.newlyViolating {
+		r := after.resources[ocid]
+		if markdown {
+			escape := strings.NewReplacer("|", "\\|").Replace
+			fmt.Fprintf(w, "| %s | %s | %s | `%s` |\n", escape(r.region), escape(r.name), escape(r.resourceType), ocid)
+		} else {
+			fmt.Fprintf(w, "  %s  %s  %s  %s\n", r.region, r.name, r.resourceType, ocid)
+		}
+	}
+}
+
+// runReportChanges is the report-changes subcommand. It compares two runs
+// given by ID, or the last two runs when none are given.
+func (cfg *config) runReportChanges(args []string) int {
+	manifests, ids, err := cfg.runHistory()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading run history: %v\n", err)
+		return 1
+	}
+
+	var from, to string
+	switch {
+	case len(args) == 2:
+		from, to = args[0], args[1]
+	case len(args) == 0 || (len(args) == 1 && args[0] == "last"):
+		if len(ids) < 2 {
+			fmt.Fprintf(os.Stderr, "report-changes needs at least two runs in %s, found %d\n", cfg.dataDir, len(ids))
+			return 1
+		}
+		from, to = ids[len(ids)-2], ids[len(ids)-1]
+	default:
+		fmt.Fprintln(os.Stderr, "usage: oci-tag-auditor [-markdown] report-changes [RUN_ID RUN_ID]")
+		return 2
+	}
+
+	snapshots := make([]*runSnapshot, 2)
+	for i, id := range []string{from, to} {
+		path, ok := manifests[id]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "no run %s in %s\n", id, cfg.dataDir)
+			return 1
+		}
+		if snapshots[i], err = cfg.loadRunSnapshot(id, path); err != nil {
+			fmt.Fprintf(os.Stderr, "error loading run %s: %v\n", id, err)
+			return 1
+		}
+	}
+
+	compareRuns(snapshots[0], snapshots[1]).write(os.Stdout, snapshots[1], cfg.changesMarkdown)
+	return 0
+}
@@ -0,0 +1,132 @@
+package auditor
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	compliantTags = `{"Oracle-Tags":{"CreatedBy":"alice"},"Ops":{"CostCenter":"42"}}`
+	violatingTags = `{"Oracle-Tags":{"CreatedBy":"alice"}}`
+)
+
+// testConfig returns a config parsed from command-line flags, with its
+// checks loaded.
+func testConfig(t testing.TB, args ...string) *config {
+	t.Helper()
+	cfg, fs := newConfig("test", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.loadChecks(); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// writeTestRun writes the main report of one run, rows of OCID, name and
+// defined tags, and the manifest that lists it.
+func writeTestRun(t *testing.T, dir, prefix, id string, rows [][3]string) {
+	t.Helper()
+	report := filepath.Join(dir, prefix+"DEFAULT_resources_"+id+".csv")
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"Profile", "Display Name", "Resource Type", "Identifier", "Defined Tags", "Freeform Tags"})
+	for _, row := range rows {
+		w.Write([]string{"DEFAULT", row[1], "Instance", row[0], row[2], ""})
+	}
+	w.Flush()
+	if err := os.WriteFile(report, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := &Manifest{RunTimestamp: id, SchemaVersion: schemaVersion, Files: []string{report}}
+	if err := manifest.Write(filepath.Join(dir, prefix+"manifest_"+id+".json")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReportChangesPrefixedRuns(t *testing.T) {
+	dir := t.TempDir()
+	writeTestRun(t, dir, "acme_", "20240101_000000", [][3]string{
+		{"ocid1.instance.a", "fixed", violatingTags},
+		{"ocid1.instance.b", "broken", compliantTags},
+		{"ocid1.instance.c", "deleted", compliantTags},
+	})
+	writeTestRun(t, dir, "acme_", "20240102_000000", [][3]string{
+		{"ocid1.instance.a", "fixed", compliantTags},
+		{"ocid1.instance.b", "broken|web", violatingTags},
+		{"ocid1.instance.d", "new", violatingTags},
+	})
+
+	for _, args := range [][]string{
+		{"-output-dir", dir, "-required-tags", "Ops.CostCenter"},
+		{"-output-dir", dir, "-required-tags", "Ops.CostCenter", "-prefix-tenancy", "-tenancy-name", "acme"},
+	} {
+		cfg := testConfig(t, args...)
+		manifests, ids, err := cfg.runHistory()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) != 2 || ids[0] != "20240101_000000" || ids[1] != "20240102_000000" {
+			t.Fatalf("%v: run IDs = %v", args, ids)
+		}
+
+		before, err := cfg.loadRunSnapshot(ids[0], manifests[ids[0]])
+		if err != nil {
+			t.Fatal(err)
+		}
+		after, err := cfg.loadRunSnapshot(ids[1], manifests[ids[1]])
+		if err != nil {
+			t.Fatal(err)
+		}
+		changes := compareRuns(before, after)
+		if got := strings.Join(changes.remediated, ","); got != "ocid1.instance.a" {
+			t.Errorf("remediated = %s", got)
+		}
+		if got := strings.Join(changes.newlyViolating, ","); got != "ocid1.instance.b" {
+			t.Errorf("newly violating = %s", got)
+		}
+		if got := strings.Join(changes.added, ","); got != "ocid1.instance.d" || changes.addedViolating != 1 {
+			t.Errorf("added = %s, %d violating", got, changes.addedViolating)
+		}
+		if got := strings.Join(changes.removed, ","); got != "ocid1.instance.c" {
+			t.Errorf("removed = %s", got)
+		}
+
+		var out bytes.Buffer
+		changes.write(&out, after, true)
+		for _, want := range []string{
+			"## Changes from run `20240101_000000` to run `20240102_000000`",
+			"- 1 resources became compliant",
+			"- 1 new resources, 1 of them non-compliant",
+			"- 3 resources before, 3 after",
+			"| Region | Display Name | Resource Type | Identifier |\n|---|---|---|---|\n",
+			"| DEFAULT | broken\\|web | Instance | `ocid1.instance.b` |\n",
+		} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("changelog does not contain %q:\n%s", want, out.String())
+			}
+		}
+	}
+}
+
+func TestReportChangesOtherTenancyPrefix(t *testing.T) {
+	dir := t.TempDir()
+	writeTestRun(t, dir, "acme_", "20240101_000000", nil)
+	writeTestRun(t, dir, "other_", "20240102_000000", nil)
+
+	cfg := testConfig(t, "-output-dir", dir, "-prefix-tenancy", "-tenancy-name", "acme")
+	_, ids, err := cfg.runHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != "20240101_000000" {
+		t.Errorf("run IDs = %v, want only the acme run", ids)
+	}
+}
@@ -0,0 +1,73 @@
+package auditor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sha256File returns the hex SHA-256 digest of a file's content.
+func sha256File(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// checksum hashes a finished output file, writes a <file>.sha256 sidecar in
+// sha256sum format and records the digest in the manifest. It returns the
+// sidecar path.
+func (run *auditRun) checksum(path string) (string, error) {
+	digest, err := sha256File(path)
+	if err != nil {
+		return "", fmt.Errorf("error hashing %s: %w", path, err)
+	}
+
+	sidecar := path + ".sha256"
+	line := fmt.Sprintf("%s  %s\n", digest, filepath.Base(path))
+	if err := os.WriteFile(sidecar, []byte(line), 0644); err != nil {
+		return "", fmt.Errorf("error writing %s: %w", sidecar, err)
+	}
+
+	run.manifest.mu.Lock()
+	defer run.manifest.mu.Unlock()
+	if run.manifest.Checksums == nil {
+		run.manifest.Checksums = make(map[string]string)
+	}
+	run.manifest.Checksums[path] = digest
+	return sidecar, nil
+}
+
+// checksumPending hashes every recorded output file that has no checksum
+// yet and records the sidecars as output files. It must only run once the
+// files are closed.
+func (run *auditRun) checksumPending() error {
+	run.manifest.mu.Lock()
+	var pending []string
+	for _, path := range run.manifest.Files {
+		if _, done := run.manifest.Checksums[path]; !done && !strings.HasSuffix(path, ".sha256") {
+			pending = append(pending, path)
+		}
+	}
+	run.manifest.mu.Unlock()
+
+	for _, path := range pending {
+		sidecar, err := run.checksum(path)
+		if err != nil {
+			return err
+		}
+		run.manifest.AddFile(sidecar)
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package auditor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// column is one field of the per-resource reports. Headers and rows are both
+// built from the same column list so they cannot drift apart.
+type column struct {
+	header string
+	value  func(section string, r ResourceSummary) string
+
+	// minimal columns come from fields the search API always returns and
+	// are the only ones kept with -minimal-fields.
+	minimal bool
+}
+
+var baseColumns = []column{
+	{header: "Profile", minimal: true, value: func(section string, _ ResourceSummary) string {
+		return section
+	}},
+	{header: "Resource Region", minimal: true, value: func(_ string, r ResourceSummary) string {
+		return resourceRegion(getStringValue(r.Identifier))
+	}},
+	{header: "Display Name", value: func(_ string, r ResourceSummary) string {
+		return getStringValue(r.DisplayName)
+	}},
+	{header: "Resource Type", minimal: true, value: func(_ string, r ResourceSummary) string {
+		return getStringValue(r.ResourceType)
+	}},
+	{header: "Identifier", minimal: true, value: func(_ string, r ResourceSummary) string {
+		return getStringValue(r.Identifier)
+	}},
+	{header: "Compartment ID", minimal: true, value: func(_ string, r ResourceSummary) string {
+		return getStringValue(r.CompartmentId)
+	}},
+	{header: "Lifecycle State", value: func(_ string, r ResourceSummary) string {
+		return getStringValue(r.LifecycleState)
+	}},
+	{header: "Time Created (UTC)", value: func(_ string, r ResourceSummary) string {
+		formattedTime, _ := formatTimeCreated(r.TimeCreated)
+		return formattedTime
+	}},
+	{header: "Days Since Creation", value: func(_ string, r ResourceSummary) string {
+		_, days := formatTimeCreated(r.TimeCreated)
+		return days
+	}},
+	{header: "Availability Domain", value: func(_ string, r ResourceSummary) string {
+		return getStringValue(r.AvailabilityDomain)
+	}},
+	{header: "Defined Tags", value: func(_ string, r ResourceSummary) string {
+		return DefinedTagsToString(r.DefinedTags)
+	}},
+	{header: "Freeform Tags", value: func(_ string, r ResourceSummary) string {
+		return FreeformTagsToString(r.FreeformTags)
+	}},
+}
+
+// schemaVersion identifies the column layouts built by columnsFor. It is
+// recorded in the manifest and must be bumped whenever an existing layout
+// changes; adding an optional column does not change the others. Version 2
+// renamed Region to Profile and added Resource Region.
+const schemaVersion = 2
+
+// legacyHeaders maps current headers to their names in reports of earlier
+// schema versions, for the readers of those reports.
+var legacyHeaders = map[string]string{"Profile": "Region"}
+
+// addLegacyHeaders lets a header index of a report from an earlier schema
+// version be looked up by the current header names.
+func addLegacyHeaders(index map[string]int) {
+	for header, old := range legacyHeaders {
+		if i, ok := index[old]; ok {
+			if _, current := index[header]; !current {
+				index[header] = i
+			}
+		}
+	}
+}
+
+// resourceRegion returns the region encoded in a resource's OCID, or
+// "global" for OCIDs without one.
+func resourceRegion(ocid string) string {
+	if region, ok := ocidRegion(ocid); ok {
+		return region
+	}
+	return "global"
+}
+
+// layoutOptions are the flags that change the per-resource columns.
+type layoutOptions struct {
+	minimal      bool
+	environment  bool
+	flatTags     bool
+	grace        bool
+	statusChange bool
+	// compartmentNames adds the Compartment Name column.
+	compartmentNames bool
+	// ownerSource adds the Owner Source column.
+	ownerSource bool
+	// namespaces adds the Tag Namespaces column.
+	namespaces bool
+}
+
+// reportColumns returns the columns of the per-resource reports for the
+// current flags, followed by the -flatten-tags and -derived-column columns.
+func (cfg *config) reportColumns() []column {
+	columns := cfg.columnsFor(layoutOptions{
+		minimal:      cfg.minimalFields,
+		environment:  cfg.environmentTag != nil,
+		flatTags:     cfg.tagsBoth && !cfg.minimalFields,
+		grace:        cfg.graceDays > 0,
+		statusChange: cfg.baseline != nil,
+
+		compartmentNames: cfg.compartmentNames != nil,
+		ownerSource:      cfg.ownerFreeformKey != "",
+		namespaces:       cfg.showNamespaces && !cfg.minimalFields,
+	})
+	for _, t := range cfg.flattenTags {
+		columns = append(columns, tagColumn(t))
+	}
+	return append(columns, cfg.derivedColumns...)
+}
+
+// tagColumn holds the value of one defined tag, empty when absent, for
+// -flatten-tags.
+func tagColumn(t tagRef) column {
+	return column{header: t.name(), value: func(_ string, r ResourceSummary) string {
+		value, _ := definedTagValue(r.DefinedTags, t.namespace, t.key)
+		return value
+	}}
+}
+
+// columnsFor returns the per-resource columns for one combination of the
+// layout options.
+func (cfg *config) columnsFor(opts layoutOptions) []column {
+	var columns []column
+	for _, c := range baseColumns {
+		if c.minimal || !opts.minimal {
+			columns = append(columns, c)
+		}
+		if c.header == "Compartment ID" && opts.compartmentNames {
+			columns = append(columns, cfg.compartmentNameColumn())
+		}
+		if c.header == "Compartment ID" && opts.environment {
+			columns = append(columns, cfg.environmentColumn())
+		}
+		if c.header == "Defined Tags" && opts.flatTags {
+			columns = append(columns, flatTagsColumn)
+		}
+		if c.header == "Defined Tags" && opts.namespaces {
+			columns = append(columns, namespacesColumn)
+		}
+		if c.header == "Freeform Tags" && opts.ownerSource {
+			columns = append(columns, cfg.ownerSourceColumn())
+		}
+	}
+	if opts.grace {
+		columns = append(columns, cfg.graceColumn())
+	}
+	if opts.statusChange {
+		columns = append(columns, cfg.statusChangeColumn())
+	}
+	return columns
+}
+
+// compartmentNameColumn is the compartment's name added by
+// -compartment-names, or its OCID when the name could not be resolved.
+func (cfg *config) compartmentNameColumn() column {
+	return column{header: "Compartment Name", minimal: true, value: func(_ string, r ResourceSummary) string {
+		return cfg.compartmentNames.name(getStringValue(r.CompartmentId))
+	}}
+}
+
+// namespacesColumn lists the defined-tag namespaces of a resource, sorted
+// and comma-separated, for -show-namespaces.
+var namespacesColumn = column{header: "Tag Namespaces", value: func(_ string, r ResourceSummary) string {
+	namespaces := make([]string, 0, len(r.DefinedTags))
+	for namespace := range r.DefinedTags {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return strings.Join(namespaces, ",")
+}}
+
+// ownerSourceColumn tells where the owner of a resource was found with
+// -owner-freeform-key: "defined", "freeform" or "none".
+func (cfg *config) ownerSourceColumn() column {
+	return column{header: "Owner Source", minimal: true, value: func(_ string, r ResourceSummary) string {
+		return cfg.ownerSource(r)
+	}}
+}
+
+// environmentColumn promotes the -environment-tag value to its own column.
+func (cfg *config) environmentColumn() column {
+	return column{header: "Environment", minimal: true, value: func(_ string, r ResourceSummary) string {
+		return cfg.environmentOf(r)
+	}}
+}
+
+// flatTagsColumn is the human-readable defined tags added by -tags-both.
+var flatTagsColumn = column{header: "Defined Tags (flat)", value: func(_ string, r ResourceSummary) string {
+	return FlattenDefinedTags(r.DefinedTags)
+}}
+
+// graceColumn marks the resources -grace-days leaves unchecked.
+func (cfg *config) graceColumn() column {
+	return column{header: "In Grace Period", value: func(_ string, r ResourceSummary) string {
+		if cfg.inGracePeriod(r) {
+			return "yes"
+		}
+		return ""
+	}}
+}
+
+// statusChangeColumn annotates rows with -baseline-compliance. In-flight
+// resources and resources in their grace period are not checked for
+// compliance and are left blank.
+func (cfg *config) statusChangeColumn() column {
+	return column{header: "Status Change", value: func(_ string, r ResourceSummary) string {
+		if cfg.exemptFromChecks(r) {
+			return ""
+		}
+		return cfg.baseline.statusChange(getStringValue(r.Identifier), len(cfg.complianceReasons(r)) > 0)
+	}}
+}
+
+func columnHeaders(columns []column) []string {
+	headers := make([]string, len(columns))
+	for i, c := range columns {
+		headers[i] = c.header
+	}
+	return headers
+}
+
+func buildRow(columns []column, section string, r ResourceSummary) []string {
+	row := make([]string, len(columns))
+	for i, c := range columns {
+		row[i] = c.value(section, r)
+	}
+	return row
+}
+
+// buildRowSafely is buildRow, converting a panic caused by unexpected
+// resource data into an error so that a single malformed resource cannot
+// abort the whole region.
+func buildRowSafely(columns []column, section string, r ResourceSummary) (row []string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic while building row: %v", p)
+		}
+	}()
+	return buildRow(columns, section, r), nil
+}
@@ -0,0 +1,58 @@
+package auditor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+)
+
+// benchResources returns resources with the fields a search result usually
+// has and a dozen defined and freeform tags each.
+func benchResources(n int) []ResourceSummary {
+	created := common.SDKTime{Time: time.Date(2024, 5, 17, 8, 0, 0, 0, time.UTC)}
+	resources := make([]ResourceSummary, n)
+	for i := range resources {
+		r := testResource(fmt.Sprintf("ocid1.instance.oc1.eu-frankfurt-1.%040d", i), compliantTags)
+		r.LifecycleState = common.String("RUNNING")
+		r.AvailabilityDomain = common.String("Uocm:EU-FRANKFURT-1-AD-1")
+		r.TimeCreated = &created
+		r.FreeformTags = make(map[string]string)
+		for j := 0; j < 12; j++ {
+			r.DefinedTags["Ops"][fmt.Sprintf("Key%d", j)] = fmt.Sprintf("value-%d", j)
+			r.FreeformTags[fmt.Sprintf("key%d", j)] = fmt.Sprintf("value-%d", j)
+		}
+		resources[i] = r
+	}
+	return resources
+}
+
+// BenchmarkBuildRow builds and writes the main report row of a resource
+// with every column and with -minimal-fields, which leaves out the tag
+// columns and their serialization.
+func BenchmarkBuildRow(b *testing.B) {
+	resources := benchResources(1000)
+	for _, bench := range []struct {
+		name string
+		args []string
+	}{
+		{"full", nil},
+		{"minimal", []string{"-minimal-fields"}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			columns := testConfig(b, bench.args...).reportColumns()
+			writer := csv.NewWriter(io.Discard)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := writer.Write(buildRow(columns, "DEFAULT", resources[i%len(resources)])); err != nil {
+					b.Fatal(err)
+				}
+			}
+			writer.Flush()
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package auditor
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// combinedWriter funnels the main report rows of every region into one file
+// through a single writer goroutine. The channel is bounded, so producers
+// block once -combined-buffer rows are pending instead of buffering without
+// limit.
+type combinedWriter struct {
+	rows chan []string
+	done chan error
+}
+
+func (run *auditRun) startCombinedWriter(headers []string, buffer int) (*combinedWriter, error) {
+	path := run.outputPath(fmt.Sprintf("all_regions_resources_%s.csv", run.timestamp))
+	report, err := run.openReport(path, headers)
+	if err != nil {
+		return nil, fmt.Errorf("error creating combined report: %w", err)
+	}
+	report.holdSorted(headers, run.cfg.sortBy)
+
+	c := &combinedWriter{
+		rows: make(chan []string, buffer),
+		done: make(chan error, 1),
+	}
+	go func() {
+		var writeErr error
+		for row := range c.rows {
+			// Keep draining after a failure so producers never block.
+			if writeErr != nil {
+				continue
+			}
+			if err := report.Write(row); err != nil {
+				writeErr = fmt.Errorf("error writing combined report: %w", err)
+				slog.Error("Error writing combined report", "error", err)
+			}
+		}
+		if err := report.Close(); err != nil && writeErr == nil {
+			writeErr = fmt.Errorf("error closing combined report: %w", err)
+		}
+		c.done <- writeErr
+	}()
+	return c, nil
+}
+
+// Write queues a row, blocking while the buffer is full.
+func (c *combinedWriter) Write(row []string) {
+	c.rows <- row
+}
+
+// Close waits for every queued row to be written, then flushes and closes
+// the file. It must only be called once all producers have finished.
+func (c *combinedWriter) Close() error {
+	close(c.rows)
+	return <-c.done
+}
@@ -0,0 +1,69 @@
+package auditor
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestCombinedWriter(t *testing.T) {
+	dir := t.TempDir()
+	run := newAuditRun(testConfig(t, "-output-dir", dir), func() {})
+	combined, err := run.startCombinedWriter([]string{"Profile", "Identifier"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for region := 0; region < 4; region++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				combined.Write([]string{fmt.Sprint(region), fmt.Sprint(i)})
+			}
+		}()
+	}
+	wg.Wait()
+	if err := combined.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if records := readReport(t, dir, "all_regions_resources_*.csv"); len(records) != 401 {
+		t.Errorf("combined report has %d records, want a header and 400 rows", len(records))
+	}
+}
+
+// BenchmarkCombinedWriter feeds rows from eight region goroutines into the
+// combined report with several -combined-buffer sizes. Producers wait for
+// the writer once the buffer is full, so queuing a row allocates nothing
+// and memory does not grow with the row count.
+func BenchmarkCombinedWriter(b *testing.B) {
+	row := buildRow(testConfig(b).reportColumns(), "DEFAULT", benchResources(1)[0])
+	for _, buffer := range []int{1, 100, 1000, 10000} {
+		b.Run(fmt.Sprintf("buffer=%d", buffer), func(b *testing.B) {
+			run := newAuditRun(testConfig(b, "-output-dir", b.TempDir()), func() {})
+			combined, err := run.startCombinedWriter(columnHeaders(run.cfg.reportColumns()), buffer)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			var wg sync.WaitGroup
+			const producers = 8
+			for p := 0; p < producers; p++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := p; i < b.N; i += producers {
+						combined.Write(row)
+					}
+				}()
+			}
+			wg.Wait()
+			if err := combined.Close(); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
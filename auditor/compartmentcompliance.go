@@ -0,0 +1,102 @@
+package auditor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+// compartmentTally counts the checked resources of a compartment, and those
+// carrying all their required tags. In-flight resources and resources in
+// their grace period are not checked and are left out.
+type compartmentTally struct {
+	resources int
+	compliant int
+}
+
+func (c compartmentTally) percent() float64 {
+	if c.resources == 0 {
+		return 100
+	}
+	return float64(c.compliant) * 100 / float64(c.resources)
+}
+
+// WriteCompartmentCompliance writes compliance_<timestamp>.csv: per
+// compartment, merged across regions, the resources checked, those carrying
+// all their required tags and their share, least compliant first, and logs
+// the tenancy-wide share.
+func (s *runSummary) WriteCompartmentCompliance(run *auditRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	compartments := make(map[string]*compartmentTally)
+	var total compartmentTally
+	for _, t := range s.regions {
+		for id, c := range t.byCompartment {
+			merged, ok := compartments[id]
+			if !ok {
+				merged = &compartmentTally{}
+				compartments[id] = merged
+			}
+			merged.resources += c.resources
+			merged.compliant += c.compliant
+			total.resources += c.resources
+			total.compliant += c.compliant
+		}
+	}
+
+	// Ties are broken by size, so the compartments with the most
+	// non-compliant resources come first.
+	ids := make([]string, 0, len(compartments))
+	for id := range compartments {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := compartments[ids[i]], compartments[ids[j]]
+		if a.percent() != b.percent() {
+			return a.percent() < b.percent()
+		}
+		if a.resources != b.resources {
+			return a.resources > b.resources
+		}
+		return ids[i] < ids[j]
+	})
+
+	file, err := run.createReport(run.outputPath(fmt.Sprintf("compliance_%s.csv", run.timestamp)))
+	if err != nil {
+		return fmt.Errorf("error creating compartment compliance report: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	headers := []string{"Compartment ID"}
+	if run.cfg.compartmentNames != nil {
+		headers = append(headers, "Compartment Name")
+	}
+	headers = append(headers, "Resources", "Compliant", "Compliant (%)")
+	if err := writer.Write(headers); err != nil {
+		return fmt.Errorf("error writing compartment compliance header: %w", err)
+	}
+
+	for _, id := range ids {
+		c := compartments[id]
+		row := []string{id}
+		if run.cfg.compartmentNames != nil {
+			row = append(row, run.cfg.compartmentNames.name(id))
+		}
+		row = append(row, fmt.Sprintf("%d", c.resources), fmt.Sprintf("%d", c.compliant), fmt.Sprintf("%.1f", c.percent()))
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("error writing compartment compliance report: %w", err)
+		}
+	}
+
+	percent := "N/A"
+	if total.resources > 0 {
+		percent = fmt.Sprintf("%.1f", total.percent())
+	}
+	slog.Info("Compartment compliance", "compartments", len(ids), "resources", total.resources, "compliant_percent", percent)
+	return nil
+}
@@ -0,0 +1,114 @@
+package auditor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// compartmentNameCache maps compartment OCIDs to their names for the
+// Compartment Name column. Each tenancy's compartments are listed once with
+// a single paginated ListCompartments subtree call, so rows are resolved
+// without further API calls. It is safe for concurrent use.
+type compartmentNameCache struct {
+	mu    sync.Mutex
+	names map[string]string
+}
+
+func newCompartmentNameCache() *compartmentNameCache {
+	return &compartmentNameCache{names: make(map[string]string)}
+}
+
+// load lists the compartments of every tenancy the profiles belong to,
+// using the first profile of each tenancy. The root compartment is named
+// after the tenancy when its name is known. A tenancy whose compartments
+// cannot be listed is logged and its resources keep their OCIDs.
+func (c *compartmentNameCache) load(ctx context.Context, run *auditRun, configPath string, profiles []string) {
+	loaded := make(map[string]bool)
+	for _, profile := range profiles {
+		tenancyID, err := run.cfg.profileTenancyID(configPath, profile)
+		if err != nil || loaded[tenancyID] {
+			continue
+		}
+		loaded[tenancyID] = true
+
+		idClient, _, err := run.cfg.newIdentityClient(configPath, profile)
+		if err != nil {
+			slog.Warn("Compartment names are not resolved", "tenancy", tenancyID, "error", err)
+			continue
+		}
+		compartments, err := listCompartments(ctx, idClient, tenancyID)
+		if err != nil {
+			slog.Warn("Compartment names are not resolved", "tenancy", tenancyID, "error", err)
+			continue
+		}
+
+		c.mu.Lock()
+		if name := run.tenancies.name(tenancyID); name != "" {
+			c.names[tenancyID] = name
+		}
+		for _, compartment := range compartments {
+			c.names[getStringValue(compartment.Id)] = getStringValue(compartment.Name)
+		}
+		c.mu.Unlock()
+		slog.Info("Resolved compartment names", "tenancy", tenancyID, "compartments", len(compartments))
+	}
+}
+
+// name returns a compartment's name, or its OCID when it is unknown.
+func (c *compartmentNameCache) name(ocid string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if name, ok := c.names[ocid]; ok {
+		return name
+	}
+	return ocid
+}
+
+// compartmentSubtree returns the OCIDs of a compartment and, unless exact
+// is set, every active compartment below it. ListCompartments only lists a
+// subtree from the root compartment, so the tenancy's compartments are
+// listed once and the subtree is walked through their parents. The subtree
+// of the root compartment is the whole tenancy, for which nil is returned.
+func (cfg *config) compartmentSubtree(ctx context.Context, configPath, compartmentID string, exact bool) (map[string]bool, error) {
+	scope := map[string]bool{compartmentID: true}
+	if exact {
+		return scope, nil
+	}
+
+	idClient, tenancyID, err := cfg.newIdentityClient(configPath, "DEFAULT")
+	if err != nil {
+		return nil, err
+	}
+	if compartmentID == tenancyID {
+		return nil, nil
+	}
+	compartments, err := listCompartments(ctx, idClient, tenancyID)
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[string][]string)
+	found := false
+	for _, compartment := range compartments {
+		id := getStringValue(compartment.Id)
+		parent := getStringValue(compartment.CompartmentId)
+		children[parent] = append(children[parent], id)
+		found = found || id == compartmentID
+	}
+	if !found {
+		return nil, fmt.Errorf("compartment %s is not an accessible active compartment of tenancy %s", compartmentID, tenancyID)
+	}
+	for pending := []string{compartmentID}; len(pending) > 0; {
+		id := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		for _, child := range children[id] {
+			if !scope[child] {
+				scope[child] = true
+				pending = append(pending, child)
+			}
+		}
+	}
+	return scope, nil
+}
@@ -0,0 +1,52 @@
+package auditor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// complianceReason is one reason a resource is non-compliant. The id is
+// stable and used in file names; the details are human readable.
+type complianceReason struct {
+	id      string
+	details string
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// reasonSlug turns a tag name into a file-name friendly fragment.
+func reasonSlug(s string) string {
+	return strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(s), "_"), "_")
+}
+
+// complianceReasons evaluates every configured check against a resource and
+// returns the reasons it fails, in a stable order. Each missing required tag
+// is a reason of its own, so every -by-reason file is one tag to fix. It
+// returns nil for a compliant resource.
+func (cfg *config) complianceReasons(r ResourceSummary) []complianceReason {
+	var reasons []complianceReason
+
+	if note := cfg.tagCountNote(definedTagCount(r.DefinedTags)); note != "" {
+		reasons = append(reasons, complianceReason{id: "missing_tags", details: note})
+	}
+	for _, t := range hasRequiredTags(r.DefinedTags, cfg.requiredTagsFor(getStringValue(r.ResourceType))) {
+		reasons = append(reasons, complianceReason{
+			id:      "missing_" + reasonSlug(t.name()),
+			details: "missing required tag " + t.name(),
+		})
+	}
+	if hasOwner, note := cfg.ownerStatus(r); !hasOwner {
+		if note == "" {
+			note = fmt.Sprintf("missing %s tag", cfg.ownerTagLabel())
+		}
+		reasons = append(reasons, complianceReason{id: "no_owner", details: note})
+	}
+	for _, v := range checkTagRules(r.DefinedTags, cfg.tagRules) {
+		reasons = append(reasons, complianceReason{
+			id:      "invalid_" + reasonSlug(v.tag),
+			details: fmt.Sprintf("%s=%s is %s", v.tag, v.value, v.reason),
+		})
+	}
+	return reasons
+}
@@ -0,0 +1,31 @@
+package auditor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestComplianceReasonsPerRequiredTag(t *testing.T) {
+	cfg := testConfig(t, "-required-tags", "Ops.CostCenter,Ops.Project")
+	tests := []struct {
+		name        string
+		definedTags string
+		want        []string
+	}{
+		{"compliant", `{"Oracle-Tags":{"CreatedBy":"alice"},"Ops":{"CostCenter":"42","Project":"web"}}`, nil},
+		{"one missing", `{"Oracle-Tags":{"CreatedBy":"alice"},"Ops":{"CostCenter":"42"}}`, []string{"missing_ops_project"}},
+		{"blank value", `{"Oracle-Tags":{"CreatedBy":"alice"},"Ops":{"CostCenter":" ","Project":"web"}}`, []string{"missing_ops_costcenter"}},
+		{"no tags", `{}`, []string{"missing_tags", "missing_ops_costcenter", "missing_ops_project", "no_owner"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			for _, reason := range cfg.complianceReasons(testResource("ocid1.instance.a", tt.definedTags)) {
+				ids = append(ids, reason.id)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("reasons = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package auditor
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+)
+
+// aimdLimiter bounds the search requests in flight across all regions and
+// adapts the bound: it grows by one after a full window of unthrottled
+// requests sent while every slot was in use, and halves on every throttled
+// (HTTP 429) response, staying between min and max. A limit that is not
+// reached is not raised, since it has not been tested. It is safe for
+// concurrent use.
+type aimdLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	min       int
+	max       int
+	inFlight  int
+	successes int
+	// freed is closed, and replaced, whenever a slot is released or the
+	// limit changes, waking the requests waiting for a slot.
+	freed chan struct{}
+}
+
+// newAIMDLimiter returns a limiter that starts at the conservative end.
+func newAIMDLimiter(min, max int) *aimdLimiter {
+	return &aimdLimiter{limit: min, min: min, max: max, freed: make(chan struct{})}
+}
+
+// acquire blocks until a request may be sent or ctx is done.
+func (l *aimdLimiter) acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.inFlight < l.limit {
+			l.inFlight++
+			l.mu.Unlock()
+			return nil
+		}
+		freed := l.freed
+		l.mu.Unlock()
+
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release ends a request and adjusts the limit by its outcome.
+func (l *aimdLimiter) release(throttled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// Requests sent above a limit that was just lowered do not count.
+	saturated := l.inFlight == l.limit
+	l.inFlight--
+
+	switch {
+	case throttled:
+		l.successes = 0
+		next := l.limit / 2
+		if next < l.min {
+			next = l.min
+		}
+		if next < l.limit {
+			l.limit = next
+			slog.Warn("Adaptive concurrency: throttled, lowered the limit", "limit", l.limit)
+		}
+	case saturated && l.limit < l.max:
+		l.successes++
+		if l.successes >= l.limit {
+			l.successes = 0
+			l.limit++
+			slog.Info("Adaptive concurrency: raised the limit", "limit", l.limit)
+		}
+	}
+	close(l.freed)
+	l.freed = make(chan struct{})
+}
+
+// isThrottled reports whether err is an HTTP 429 response.
+func isThrottled(err error) bool {
+	serviceErr, ok := common.IsServiceError(err)
+	return ok && serviceErr.GetHTTPStatusCode() == 429
+}
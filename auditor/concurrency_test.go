@@ -0,0 +1,92 @@
+package auditor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// saturate acquires every free slot of l and returns how many it took.
+func saturate(t *testing.T, l *aimdLimiter) int {
+	t.Helper()
+	n := l.limit - l.inFlight
+	for i := 0; i < n; i++ {
+		if err := l.acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return n
+}
+
+func TestAIMDLimiter(t *testing.T) {
+	l := newAIMDLimiter(2, 4)
+
+	// Requests that never fill the limit do not raise it.
+	for i := 0; i < 10; i++ {
+		if err := l.acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		l.release(false)
+	}
+	if l.limit != 2 {
+		t.Fatalf("unsaturated: limit = %d, want 2", l.limit)
+	}
+
+	// A window of as many saturated successes as the limit raises it by
+	// one, up to max.
+	for _, want := range []int{3, 4, 4} {
+		for successes := 0; successes < l.limit; successes++ {
+			saturate(t, l)
+			l.release(false)
+			for l.inFlight > 0 {
+				l.release(false)
+			}
+		}
+		if l.limit != want {
+			t.Fatalf("saturated: limit = %d, want %d", l.limit, want)
+		}
+	}
+
+	// A throttled response halves the limit, never below min.
+	for _, want := range []int{2, 2} {
+		saturate(t, l)
+		l.release(true)
+		for l.inFlight > 0 {
+			l.release(false)
+		}
+		if l.limit != want {
+			t.Fatalf("throttled: limit = %d, want %d", l.limit, want)
+		}
+	}
+	// Throttling resets the window.
+	saturate(t, l)
+	l.release(false)
+	l.release(false)
+	if l.limit != 2 || l.successes != 1 {
+		t.Errorf("after throttling: limit %d, %d successes, want 2 and 1", l.limit, l.successes)
+	}
+}
+
+func TestAIMDLimiterAcquireWaits(t *testing.T) {
+	l := newAIMDLimiter(1, 1)
+	saturate(t, l)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("acquire on a full limiter = %v, want the context's error", err)
+	}
+
+	acquired := make(chan error)
+	go func() { acquired <- l.acquire(context.Background()) }()
+	l.release(false)
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acquire was not woken by release")
+	}
+}
@@ -0,0 +1,166 @@
+package auditor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// tagRef is a parsed "Namespace.Key" reference to a defined tag.
+type tagRef struct {
+	namespace string
+	key       string
+}
+
+func (t tagRef) name() string {
+	return t.namespace + "." + t.key
+}
+
+// label is name for tags without a namespace too, such as the CreatedBy
+// owner key looked up in any namespace.
+func (t tagRef) label() string {
+	if t.namespace == "" {
+		return t.key
+	}
+	return t.name()
+}
+
+// parseTagRefs parses a comma-separated list of "Namespace.Key" references.
+func parseTagRefs(list string) ([]tagRef, error) {
+	var refs []tagRef
+	for _, item := range splitList(list) {
+		namespace, key, err := parseTagRef(item)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, tagRef{namespace: namespace, key: key})
+	}
+	return refs, nil
+}
+
+// costTagColumns are the fixed leading columns of the cost tag report.
+var costTagColumns = []string{"Region", "Display Name", "Resource Type", "Identifier", "Compartment ID"}
+
+func (cfg *config) costTagHeaders() []string {
+	headers := append([]string{}, costTagColumns...)
+	for _, t := range cfg.costTags {
+		headers = append(headers, t.name())
+	}
+	return append(headers, "Missing Cost Tags")
+}
+
+// costTagRow returns the cost tag report row of a resource and whether all
+// cost tags are present.
+func (cfg *config) costTagRow(section string, r ResourceSummary) ([]string, bool) {
+	row := []string{
+		section,
+		getStringValue(r.DisplayName),
+		getStringValue(r.ResourceType),
+		getStringValue(r.Identifier),
+		getStringValue(r.CompartmentId),
+	}
+
+	var missing []string
+	for _, t := range cfg.costTags {
+		value, ok := definedTagValue(r.DefinedTags, t.namespace, t.key)
+		if !ok || strings.TrimSpace(value) == "" {
+			missing = append(missing, t.name())
+		}
+		row = append(row, value)
+	}
+	return append(row, strings.Join(missing, ", ")), len(missing) == 0
+}
+
+// costCoverage tallies complete cost tagging per region and per resource
+// type. It is fed by an OnResource hook and is safe for concurrent use.
+type costCoverage struct {
+	cfg    *config
+	mu     sync.Mutex
+	scopes map[string]map[string]*costTally
+}
+
+type costTally struct {
+	resources int
+	complete  int
+}
+
+func newCostCoverage(cfg *config) *costCoverage {
+	return &costCoverage{cfg: cfg, scopes: map[string]map[string]*costTally{
+		"Region":        {},
+		"Resource Type": {},
+		"Environment":   {},
+	}}
+}
+
+func (c *costCoverage) onResource(region string, r ResourceSummary) {
+	_, complete := c.cfg.costTagRow(region, r)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	add := func(scope, name string) {
+		t, ok := c.scopes[scope][name]
+		if !ok {
+			t = &costTally{}
+			c.scopes[scope][name] = t
+		}
+		t.resources++
+		if complete {
+			t.complete++
+		}
+	}
+	add("Region", region)
+	add("Resource Type", getStringValue(r.ResourceType))
+	if c.cfg.environmentTag != nil {
+		add("Environment", c.cfg.environmentOf(r))
+	}
+}
+
+// Write writes the percentage of fully cost-tagged resources, per region,
+// per resource type and, with -environment-tag, per environment, least
+// complete first.
+func (c *costCoverage) Write(run *auditRun) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	file, err := run.createReport(run.outputPath(fmt.Sprintf("cost_tag_coverage_%s.csv", run.timestamp)))
+	if err != nil {
+		return fmt.Errorf("error creating cost tag coverage report: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	if err := writer.Write([]string{"Scope", "Name", "Resources", "Fully Cost Tagged", "Complete (%)"}); err != nil {
+		return fmt.Errorf("error writing cost tag coverage header: %w", err)
+	}
+
+	for _, scope := range []string{"Region", "Resource Type", "Environment"} {
+		tallies := c.scopes[scope]
+		names := make([]string, 0, len(tallies))
+		for name := range tallies {
+			names = append(names, name)
+		}
+		percent := func(name string) float64 {
+			return float64(tallies[name].complete) * 100 / float64(tallies[name].resources)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if percent(names[i]) != percent(names[j]) {
+				return percent(names[i]) < percent(names[j])
+			}
+			return names[i] < names[j]
+		})
+
+		for _, name := range names {
+			t := tallies[name]
+			row := []string{scope, name, fmt.Sprintf("%d", t.resources), fmt.Sprintf("%d", t.complete), fmt.Sprintf("%.1f", percent(name))}
+			if err := writer.Write(row); err != nil {
+				return fmt.Errorf("error writing cost tag coverage report: %w", err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package auditor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// tagCoverage counts, across all regions, how many resources carry each tag
+// key. It is fed by an OnResource hook and is safe for concurrent use.
+type tagCoverage struct {
+	cfg            *config
+	mu             sync.Mutex
+	totalResources int
+	buckets        map[string]*coverageBucket
+}
+
+type coverageBucket struct {
+	kind      string
+	key       string
+	resources int
+	casings   map[string]bool
+}
+
+func newTagCoverage(cfg *config) *tagCoverage {
+	return &tagCoverage{cfg: cfg, buckets: make(map[string]*coverageBucket)}
+}
+
+// bucketKey returns the aggregation key for a tag key. With
+// -normalize-tag-keys, keys differing only in case share a bucket.
+func (cfg *config) bucketKey(kind, key string) string {
+	if cfg.normalizeTagKeys {
+		key = strings.ToLower(key)
+	}
+	return kind + "\x00" + key
+}
+
+func (c *tagCoverage) onResource(_ string, r ResourceSummary) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.totalResources++
+
+	// A resource counts once per bucket even if it carries several casings
+	// of the same key.
+	seen := make(map[string]bool)
+	add := func(kind, key string) {
+		bk := c.cfg.bucketKey(kind, key)
+		b, ok := c.buckets[bk]
+		if !ok {
+			b = &coverageBucket{kind: kind, key: key, casings: make(map[string]bool)}
+			if c.cfg.normalizeTagKeys {
+				b.key = strings.ToLower(key)
+			}
+			c.buckets[bk] = b
+		}
+		b.casings[key] = true
+		if !seen[bk] {
+			seen[bk] = true
+			b.resources++
+		}
+	}
+
+	for namespace, tags := range r.DefinedTags {
+		for key := range tags {
+			add("defined", namespace+"."+key)
+		}
+	}
+	for key := range r.FreeformTags {
+		add("freeform", key)
+	}
+}
+
+// Write writes the coverage report, most widely used keys first.
+func (c *tagCoverage) Write(run *auditRun) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	buckets := make([]*coverageBucket, 0, len(c.buckets))
+	for _, b := range c.buckets {
+		buckets = append(buckets, b)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].resources != buckets[j].resources {
+			return buckets[i].resources > buckets[j].resources
+		}
+		return buckets[i].kind+buckets[i].key < buckets[j].kind+buckets[j].key
+	})
+
+	file, err := run.createReport(run.outputPath(fmt.Sprintf("tag_coverage_%s.csv", run.timestamp)))
+	if err != nil {
+		return fmt.Errorf("error creating tag coverage report: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	headers := []string{"Tag Kind", "Tag Key", "Resources", "Coverage (%)", "Observed Casings"}
+	if err := writer.Write(headers); err != nil {
+		return fmt.Errorf("error writing tag coverage header: %w", err)
+	}
+
+	for _, b := range buckets {
+		var casings []string
+		for k := range b.casings {
+			casings = append(casings, k)
+		}
+		sort.Strings(casings)
+
+		// Only note casings when they actually differ.
+		note := ""
+		if len(casings) > 1 {
+			note = strings.Join(casings, ", ")
+		}
+
+		coverage := 0.0
+		if c.totalResources > 0 {
+			coverage = float64(b.resources) * 100 / float64(c.totalResources)
+		}
+
+		row := []string{b.kind, b.key, fmt.Sprintf("%d", b.resources), fmt.Sprintf("%.1f", coverage), note}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("error writing tag coverage report: %w", err)
+		}
+	}
+	return nil
+}
+
+// namespaceUsage counts, across all regions, how many resources use each
+// defined-tag namespace. It is fed by an OnResource hook and is safe for
+// concurrent use.
+type namespaceUsage struct {
+	mu          sync.Mutex
+	counts      map[string]int
+	noNamespace int
+}
+
+func newNamespaceUsage() *namespaceUsage {
+	return &namespaceUsage{counts: make(map[string]int)}
+}
+
+func (u *namespaceUsage) onResource(_ string, r ResourceSummary) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if len(r.DefinedTags) == 0 {
+		u.noNamespace++
+		return
+	}
+	for namespace := range r.DefinedTags {
+		u.counts[namespace]++
+	}
+}
+
+// Write writes the namespace usage report, most used namespaces first, with
+// resources that use no namespace at all on a final separate line.
+func (u *namespaceUsage) Write(run *auditRun) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	namespaces := make([]string, 0, len(u.counts))
+	for namespace := range u.counts {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Slice(namespaces, func(i, j int) bool {
+		if u.counts[namespaces[i]] != u.counts[namespaces[j]] {
+			return u.counts[namespaces[i]] > u.counts[namespaces[j]]
+		}
+		return namespaces[i] < namespaces[j]
+	})
+
+	file, err := run.createReport(run.outputPath(fmt.Sprintf("namespace_usage_%s.csv", run.timestamp)))
+	if err != nil {
+		return fmt.Errorf("error creating namespace usage report: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	if err := writer.Write([]string{"Namespace", "Resources"}); err != nil {
+		return fmt.Errorf("error writing namespace usage header: %w", err)
+	}
+	for _, namespace := range namespaces {
+		if err := writer.Write([]string{namespace, fmt.Sprintf("%d", u.counts[namespace])}); err != nil {
+			return fmt.Errorf("error writing namespace usage report: %w", err)
+		}
+	}
+	if err := writer.Write([]string{"(no namespace)", fmt.Sprintf("%d", u.noNamespace)}); err != nil {
+		return fmt.Errorf("error writing namespace usage report: %w", err)
+	}
+	return nil
+}
+
+// typeInventory counts resources per resource type, tenancy-wide and per
+// region. It is fed by an OnResource hook and is safe for concurrent use.
+type typeInventory struct {
+	mu       sync.Mutex
+	total    map[string]int
+	byRegion map[string]map[string]int
+}
+
+func newTypeInventory() *typeInventory {
+	return &typeInventory{
+		total:    make(map[string]int),
+		byRegion: make(map[string]map[string]int),
+	}
+}
+
+func (t *typeInventory) onResource(region string, r ResourceSummary) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	resourceType := getStringValue(r.ResourceType)
+	t.total[resourceType]++
+	if t.byRegion[region] == nil {
+		t.byRegion[region] = make(map[string]int)
+	}
+	t.byRegion[region][resourceType]++
+}
+
+// byCountDesc returns the keys of counts, largest count first.
+func byCountDesc(counts map[string]int) []string {
+	keys := sortedKeys(counts)
+	sort.SliceStable(keys, func(i, j int) bool { return counts[keys[i]] > counts[keys[j]] })
+	return keys
+}
+
+// Write writes the inventory: tenancy-wide counts first, under the region
+// "(all)", then each region's counts. Within each block the most common
+// types come first.
+func (t *typeInventory) Write(run *auditRun) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	file, err := run.createReport(run.outputPath(fmt.Sprintf("resource_type_inventory_%s.csv", run.timestamp)))
+	if err != nil {
+		return fmt.Errorf("error creating resource type inventory: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	if err := writer.Write([]string{"Region", "Resource Type", "Resources"}); err != nil {
+		return fmt.Errorf("error writing resource type inventory header: %w", err)
+	}
+
+	write := func(region string, counts map[string]int) error {
+		for _, resourceType := range byCountDesc(counts) {
+			if err := writer.Write([]string{region, resourceType, fmt.Sprintf("%d", counts[resourceType])}); err != nil {
+				return fmt.Errorf("error writing resource type inventory: %w", err)
+			}
+		}
+		return nil
+	}
+
+	if err := write("(all)", t.total); err != nil {
+		return err
+	}
+	regions := make([]string, 0, len(t.byRegion))
+	for region := range t.byRegion {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	for _, region := range regions {
+		if err := write(region, t.byRegion[region]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
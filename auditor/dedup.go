@@ -0,0 +1,85 @@
+package auditor
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// dedupReport collects the main report rows of every region keyed by OCID,
+// so a resource returned by several regions' searches, such as a global
+// IAM resource, is written once. Rows are held in memory until every region
+// has finished. It is safe for concurrent use.
+type dedupReport struct {
+	headers    []string
+	identifier int
+
+	mu      sync.Mutex
+	rows    map[string][]string
+	regions map[string][]string
+}
+
+func newDedupReport(headers []string) *dedupReport {
+	d := &dedupReport{
+		headers: headers,
+		rows:    make(map[string][]string),
+		regions: make(map[string][]string),
+	}
+	for i, h := range headers {
+		if h == "Identifier" {
+			d.identifier = i
+		}
+	}
+	return d
+}
+
+// add records a region's row, keeping the first row seen for an OCID.
+func (d *dedupReport) add(section string, row []string) {
+	ocid := row[d.identifier]
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.rows[ocid]; !ok {
+		d.rows[ocid] = row
+	}
+	d.regions[ocid] = append(d.regions[ocid], section)
+}
+
+// Write writes all_regions_dedup_<timestamp>.csv, one row per OCID sorted
+// by OCID, with a Found In column listing every region that returned it.
+func (d *dedupReport) Write(run *auditRun) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	path := run.outputPath(fmt.Sprintf("all_regions_dedup_%s.csv", run.timestamp))
+	report, err := run.openReport(path, append(append([]string{}, d.headers...), "Found In"))
+	if err != nil {
+		return fmt.Errorf("error creating deduplicated report: %w", err)
+	}
+
+	ocids := make([]string, 0, len(d.rows))
+	for ocid := range d.rows {
+		ocids = append(ocids, ocid)
+	}
+	sort.Strings(ocids)
+
+	duplicates := 0
+	for _, ocid := range ocids {
+		regions := d.regions[ocid]
+		if len(regions) > 1 {
+			duplicates++
+		}
+		sort.Strings(regions)
+		if err := report.Write(append(append([]string{}, d.rows[ocid]...), strings.Join(regions, ", "))); err != nil {
+			report.Close()
+			return fmt.Errorf("error writing deduplicated report: %w", err)
+		}
+	}
+	if err := report.Close(); err != nil {
+		return fmt.Errorf("error writing deduplicated report: %w", err)
+	}
+	slog.Info("Wrote deduplicated report", "resources", len(ocids), "in_several_regions", duplicates)
+	return nil
+}
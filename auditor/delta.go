@@ -0,0 +1,151 @@
+package auditor
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// tagFingerprint is a deterministic digest of a resource's tags, taken over
+// their serialized report columns. DefinedTagsToString and
+// FreeformTagsToString both order their output, so equal tags always give
+// equal fingerprints.
+func tagFingerprint(definedTags, freeformTags string) string {
+	sum := sha256.Sum256([]byte(definedTags + "\x00" + freeformTags))
+	return hex.EncodeToString(sum[:8])
+}
+
+func resourceFingerprint(r ResourceSummary) string {
+	return tagFingerprint(DefinedTagsToString(r.DefinedTags), FreeformTagsToString(r.FreeformTags))
+}
+
+// priorReport is a main report from an earlier run, indexed by OCID.
+type priorReport struct {
+	path         string
+	headers      []string
+	rows         map[string][]string
+	fingerprints map[string]string
+}
+
+// findPriorReport returns the most recent main report for a section other
+// than the one written by this run.
+func (run *auditRun) findPriorReport(section string) (string, bool, error) {
+	current := filepath.Base(run.reportPath(section, "resources"))
+	var paths []string
+	for _, pattern := range []string{section + "_resources_*.csv", section + "_resources_*.csv.gz"} {
+		matches, err := run.historyGlob(pattern)
+		if err != nil {
+			return "", false, err
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Slice(paths, func(i, j int) bool { return filepath.Base(paths[i]) < filepath.Base(paths[j]) })
+
+	for i := len(paths) - 1; i >= 0; i-- {
+		if filepath.Base(paths[i]) < current {
+			return paths[i], true, nil
+		}
+	}
+	return "", false, nil
+}
+
+func loadPriorReport(path string) (*priorReport, error) {
+	file, err := openReportReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening prior report: %w", err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading prior report %s: %w", path, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("prior report %s is empty", path)
+	}
+
+	headers := records[0]
+	index := make(map[string]int, len(headers))
+	for i, h := range headers {
+		index[h] = i
+	}
+	for _, required := range []string{"Identifier", "Defined Tags", "Freeform Tags"} {
+		if _, ok := index[required]; !ok {
+			return nil, fmt.Errorf("prior report %s has no %q column", path, required)
+		}
+	}
+
+	prior := &priorReport{
+		path:         path,
+		headers:      headers,
+		rows:         make(map[string][]string, len(records)-1),
+		fingerprints: make(map[string]string, len(records)-1),
+	}
+	for _, record := range records[1:] {
+		ocid := record[index["Identifier"]]
+		prior.rows[ocid] = record
+		definedTags, freeformTags := record[index["Defined Tags"]], record[index["Freeform Tags"]]
+		if isTruncatedCell(definedTags) || isTruncatedCell(freeformTags) {
+			// The full tags are unknown; an empty fingerprint never
+			// reports a change.
+			prior.fingerprints[ocid] = ""
+			continue
+		}
+		prior.fingerprints[ocid] = tagFingerprint(definedTags, freeformTags)
+	}
+	return prior, nil
+}
+
+// rowFor maps a prior row onto the given headers, leaving columns the prior
+// report did not have empty.
+func (p *priorReport) rowFor(ocid string, headers []string) []string {
+	index := make(map[string]int, len(p.headers))
+	for i, h := range p.headers {
+		index[h] = i
+	}
+	addLegacyHeaders(index)
+
+	record := p.rows[ocid]
+	row := make([]string, len(headers))
+	for i, h := range headers {
+		if j, ok := index[h]; ok && j < len(record) {
+			row[i] = record[j]
+		}
+	}
+	return row
+}
+
+// classifyChange compares a resource against the prior report. It returns
+// "new", "changed", or "" when the tags are unchanged.
+func (p *priorReport) classifyChange(ocid, fingerprint string) string {
+	if p == nil {
+		return "new"
+	}
+	previous, ok := p.fingerprints[ocid]
+	switch {
+	case !ok:
+		return "new"
+	case previous != "" && previous != fingerprint:
+		return "changed"
+	}
+	return ""
+}
+
+// removed returns the OCIDs of the prior report that are not in seen, in a
+// stable order.
+func (p *priorReport) removed(seen map[string]bool) []string {
+	if p == nil {
+		return nil
+	}
+	var ocids []string
+	for ocid := range p.rows {
+		if !seen[ocid] {
+			ocids = append(ocids, ocid)
+		}
+	}
+	sort.Strings(ocids)
+	return ocids
+}
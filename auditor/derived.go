@@ -0,0 +1,376 @@
+package auditor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// repeatedFlag is a flag.Value collecting every occurrence of a repeatable
+// flag as is. Unlike stringsFlag it does not split on commas.
+type repeatedFlag []string
+
+func (f *repeatedFlag) String() string {
+	return strings.Join(*f, "; ")
+}
+
+func (f *repeatedFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// Derived columns are computed per row from a small expression language:
+//
+//	expr  = term { "+" term }              "+" concatenates
+//	term  = string | integer | field | call | "(" expr ")"
+//	call  = name "(" [ expr { "," expr } ] ")"
+//
+// Every value is a string. Expressions only read the resource being
+// written; there are no variables, loops or side effects.
+
+// derivedFields are the resource fields an expression can name.
+var derivedFields = map[string]func(section string, r ResourceSummary) string{
+	"region":             func(section string, _ ResourceSummary) string { return section },
+	"displayName":        func(_ string, r ResourceSummary) string { return getStringValue(r.DisplayName) },
+	"resourceType":       func(_ string, r ResourceSummary) string { return getStringValue(r.ResourceType) },
+	"identifier":         func(_ string, r ResourceSummary) string { return getStringValue(r.Identifier) },
+	"compartmentId":      func(_ string, r ResourceSummary) string { return getStringValue(r.CompartmentId) },
+	"lifecycleState":     func(_ string, r ResourceSummary) string { return getStringValue(r.LifecycleState) },
+	"availabilityDomain": func(_ string, r ResourceSummary) string { return getStringValue(r.AvailabilityDomain) },
+	"timeCreated": func(_ string, r ResourceSummary) string {
+		formattedTime, _ := formatTimeCreated(r.TimeCreated)
+		return formattedTime
+	},
+}
+
+// derivedFunction is a function an expression can call. literal marks the
+// arguments that must be literals, checked and converted at startup.
+type derivedFunction struct {
+	minArgs, maxArgs int
+	build            func(args []derivedExpr, literals []string) (derivedExpr, error)
+	literal          func(i int) bool
+}
+
+// derivedExpr is a compiled expression.
+type derivedExpr func(section string, r ResourceSummary) string
+
+func compiledLiteral(s string) derivedExpr {
+	return func(string, ResourceSummary) string { return s }
+}
+
+var derivedFunctions = map[string]derivedFunction{
+	"tag": {1, 1, func(_ []derivedExpr, literals []string) (derivedExpr, error) {
+		namespace, key, err := parseTagRef(literals[0])
+		if err != nil {
+			return nil, err
+		}
+		return func(_ string, r ResourceSummary) string {
+			value, _ := definedTagValue(r.DefinedTags, namespace, key)
+			return value
+		}, nil
+	}, func(int) bool { return true }},
+	"freeform": {1, 1, func(_ []derivedExpr, literals []string) (derivedExpr, error) {
+		key := literals[0]
+		return func(_ string, r ResourceSummary) string { return r.FreeformTags[key] }, nil
+	}, func(int) bool { return true }},
+	"lower": {1, 1, func(args []derivedExpr, _ []string) (derivedExpr, error) {
+		return func(section string, r ResourceSummary) string { return strings.ToLower(args[0](section, r)) }, nil
+	}, func(int) bool { return false }},
+	"upper": {1, 1, func(args []derivedExpr, _ []string) (derivedExpr, error) {
+		return func(section string, r ResourceSummary) string { return strings.ToUpper(args[0](section, r)) }, nil
+	}, func(int) bool { return false }},
+	"trim": {1, 1, func(args []derivedExpr, _ []string) (derivedExpr, error) {
+		return func(section string, r ResourceSummary) string { return strings.TrimSpace(args[0](section, r)) }, nil
+	}, func(int) bool { return false }},
+	// substr(s, start[, length]) counts characters, not bytes, and clamps
+	// out-of-range bounds.
+	"substr": {2, 3, func(args []derivedExpr, literals []string) (derivedExpr, error) {
+		start, err := strconv.Atoi(literals[1])
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("substr start must be a non-negative integer, got %q", literals[1])
+		}
+		length := -1
+		if len(literals) == 3 {
+			if length, err = strconv.Atoi(literals[2]); err != nil || length < 0 {
+				return nil, fmt.Errorf("substr length must be a non-negative integer, got %q", literals[2])
+			}
+		}
+		return func(section string, r ResourceSummary) string {
+			runes := []rune(args[0](section, r))
+			if start >= len(runes) {
+				return ""
+			}
+			runes = runes[start:]
+			if length >= 0 && length < len(runes) {
+				runes = runes[:length]
+			}
+			return string(runes)
+		}, nil
+	}, func(i int) bool { return i > 0 }},
+	// split(s, sep, index) returns the index-th field, or "" if there are
+	// fewer fields.
+	"split": {3, 3, func(args []derivedExpr, literals []string) (derivedExpr, error) {
+		sep := literals[1]
+		if sep == "" {
+			return nil, fmt.Errorf("split separator is empty")
+		}
+		index, err := strconv.Atoi(literals[2])
+		if err != nil || index < 0 {
+			return nil, fmt.Errorf("split index must be a non-negative integer, got %q", literals[2])
+		}
+		return func(section string, r ResourceSummary) string {
+			parts := strings.Split(args[0](section, r), sep)
+			if index >= len(parts) {
+				return ""
+			}
+			return parts[index]
+		}, nil
+	}, func(i int) bool { return i > 0 }},
+	"replace": {3, 3, func(args []derivedExpr, _ []string) (derivedExpr, error) {
+		return func(section string, r ResourceSummary) string {
+			return strings.ReplaceAll(args[0](section, r), args[1](section, r), args[2](section, r))
+		}, nil
+	}, func(int) bool { return false }},
+	// coalesce returns its first non-blank argument.
+	"coalesce": {1, -1, func(args []derivedExpr, _ []string) (derivedExpr, error) {
+		return func(section string, r ResourceSummary) string {
+			for _, arg := range args {
+				if value := arg(section, r); strings.TrimSpace(value) != "" {
+					return value
+				}
+			}
+			return ""
+		}, nil
+	}, func(int) bool { return false }},
+}
+
+// parseDerivedColumn parses a -derived-column "Name=expression" into a
+// report column.
+func parseDerivedColumn(spec string) (column, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return column{}, fmt.Errorf("invalid derived column %q, expected Name=expression", spec)
+	}
+	name := strings.TrimSpace(parts[0])
+	expr, err := compileDerived(parts[1])
+	if err != nil {
+		return column{}, fmt.Errorf("derived column %s: %w", name, err)
+	}
+	return column{header: name, value: expr}, nil
+}
+
+// derivedToken is a lexical token: a string or integer literal, a name, or
+// a single punctuation character.
+type derivedToken struct {
+	kind  rune // 's' string, 'n' integer, 'i' name, or the punctuation itself
+	value string
+	pos   int
+}
+
+func lexDerived(src string) ([]derivedToken, error) {
+	var tokens []derivedToken
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case strings.ContainsRune("+(),", c):
+			tokens = append(tokens, derivedToken{kind: c, pos: i})
+			i++
+		case c == '"' || c == '\'':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != c; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				b.WriteRune(runes[j])
+			}
+			if j == len(runes) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, derivedToken{kind: 's', value: b.String(), pos: i})
+			i = j + 1
+		case unicode.IsDigit(c):
+			j := i
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, derivedToken{kind: 'n', value: string(runes[i:j]), pos: i})
+			i = j
+		case unicode.IsLetter(c):
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+				j++
+			}
+			tokens = append(tokens, derivedToken{kind: 'i', value: string(runes[i:j]), pos: i})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", c, i)
+		}
+	}
+	return tokens, nil
+}
+
+// derivedParser is a recursive descent parser over the tokens of one
+// expression.
+type derivedParser struct {
+	tokens []derivedToken
+	next   int
+}
+
+func compileDerived(src string) (derivedExpr, error) {
+	tokens, err := lexDerived(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("expression is empty")
+	}
+	p := &derivedParser{tokens: tokens}
+	expr, _, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.next < len(tokens) {
+		return nil, fmt.Errorf("unexpected %s at %d", p.describe(tokens[p.next]), tokens[p.next].pos)
+	}
+	return expr, nil
+}
+
+func (p *derivedParser) describe(t derivedToken) string {
+	if t.kind == 's' || t.kind == 'n' || t.kind == 'i' {
+		return strconv.Quote(t.value)
+	}
+	return strconv.Quote(string(t.kind))
+}
+
+func (p *derivedParser) peek(kind rune) bool {
+	return p.next < len(p.tokens) && p.tokens[p.next].kind == kind
+}
+
+func (p *derivedParser) expect(kind rune) error {
+	if !p.peek(kind) {
+		if p.next == len(p.tokens) {
+			return fmt.Errorf("expected %q at end of expression", string(kind))
+		}
+		t := p.tokens[p.next]
+		return fmt.Errorf("expected %q, found %s at %d", string(kind), p.describe(t), t.pos)
+	}
+	p.next++
+	return nil
+}
+
+// expr parses a concatenation. For a single literal term it also returns
+// the literal, so functions can require literal arguments.
+func (p *derivedParser) expr() (derivedExpr, *string, error) {
+	first, literal, err := p.term()
+	if err != nil {
+		return nil, nil, err
+	}
+	terms := []derivedExpr{first}
+	for p.peek('+') {
+		p.next++
+		term, _, err := p.term()
+		if err != nil {
+			return nil, nil, err
+		}
+		terms = append(terms, term)
+	}
+	if len(terms) == 1 {
+		return first, literal, nil
+	}
+	return func(section string, r ResourceSummary) string {
+		var b strings.Builder
+		for _, term := range terms {
+			b.WriteString(term(section, r))
+		}
+		return b.String()
+	}, nil, nil
+}
+
+func (p *derivedParser) term() (derivedExpr, *string, error) {
+	if p.next == len(p.tokens) {
+		return nil, nil, fmt.Errorf("unexpected end of expression")
+	}
+	t := p.tokens[p.next]
+	p.next++
+
+	switch t.kind {
+	case 's', 'n':
+		value := t.value
+		return compiledLiteral(value), &value, nil
+	case '(':
+		expr, literal, err := p.expr()
+		if err != nil {
+			return nil, nil, err
+		}
+		return expr, literal, p.expect(')')
+	case 'i':
+		if !p.peek('(') {
+			field, ok := derivedFields[t.value]
+			if !ok {
+				return nil, nil, fmt.Errorf("unknown field %q at %d", t.value, t.pos)
+			}
+			return field, nil, nil
+		}
+		expr, err := p.call(t)
+		return expr, nil, err
+	}
+	return nil, nil, fmt.Errorf("unexpected %s at %d", p.describe(t), t.pos)
+}
+
+func (p *derivedParser) call(name derivedToken) (derivedExpr, error) {
+	fn, ok := derivedFunctions[name.value]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at %d", name.value, name.pos)
+	}
+	p.next++ // "("
+
+	var (
+		args     []derivedExpr
+		literals []string
+	)
+	for !p.peek(')') {
+		if len(args) > 0 {
+			if err := p.expect(','); err != nil {
+				return nil, err
+			}
+		}
+		arg, literal, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if fn.literal(len(args)) {
+			if literal == nil {
+				return nil, fmt.Errorf("%s argument %d must be a literal", name.value, len(args)+1)
+			}
+			literals = append(literals, *literal)
+		} else {
+			literals = append(literals, "")
+		}
+		args = append(args, arg)
+	}
+	p.next++ // ")"
+
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, fmt.Errorf("%s takes %s arguments, got %d", name.value, arity(fn), len(args))
+	}
+	expr, err := fn.build(args, literals)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name.value, err)
+	}
+	return expr, nil
+}
+
+func arity(fn derivedFunction) string {
+	switch {
+	case fn.maxArgs < 0:
+		return fmt.Sprintf("at least %d", fn.minArgs)
+	case fn.minArgs == fn.maxArgs:
+		return fmt.Sprintf("%d", fn.minArgs)
+	}
+	return fmt.Sprintf("%d to %d", fn.minArgs, fn.maxArgs)
+}
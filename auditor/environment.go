@@ -0,0 +1,117 @@
+package auditor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// unknownEnvironment is the environment of resources without the
+// -environment-tag tag.
+const unknownEnvironment = "unknown"
+
+// environmentOf returns a resource's -environment-tag value, or
+// unknownEnvironment when it has none.
+func (cfg *config) environmentOf(r ResourceSummary) string {
+	if cfg.environmentTag == nil {
+		return unknownEnvironment
+	}
+	value, ok := definedTagValue(r.DefinedTags, cfg.environmentTag.namespace, cfg.environmentTag.key)
+	if !ok || strings.TrimSpace(value) == "" {
+		return unknownEnvironment
+	}
+	return value
+}
+
+// environmentSummary tallies compliance per environment across all regions.
+// It is fed by an OnResource hook and is safe for concurrent use.
+type environmentSummary struct {
+	cfg     *config
+	mu      sync.Mutex
+	tallies map[string]*environmentTally
+}
+
+type environmentTally struct {
+	resources    int
+	inFlight     int
+	grace        int
+	nonCompliant int
+}
+
+func newEnvironmentSummary(cfg *config) *environmentSummary {
+	return &environmentSummary{cfg: cfg, tallies: make(map[string]*environmentTally)}
+}
+
+func (s *environmentSummary) onResource(_ string, r ResourceSummary) {
+	environment := s.cfg.environmentOf(r)
+	inFlight := s.cfg.isTransitional(r)
+	grace := !inFlight && s.cfg.inGracePeriod(r)
+	nonCompliant := !inFlight && !grace && len(s.cfg.complianceReasons(r)) > 0
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tallies[environment]
+	if !ok {
+		t = &environmentTally{}
+		s.tallies[environment] = t
+	}
+	t.resources++
+	if inFlight {
+		t.inFlight++
+	}
+	if grace {
+		t.grace++
+	}
+	if nonCompliant {
+		t.nonCompliant++
+	}
+}
+
+// Write writes the compliance of each environment, largest first. In-flight
+// resources and resources in their grace period are not checked and are
+// left out of the percentage.
+func (s *environmentSummary) Write(run *auditRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := run.createReport(run.outputPath(fmt.Sprintf("environment_summary_%s.csv", run.timestamp)))
+	if err != nil {
+		return fmt.Errorf("error creating environment summary: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	headers := []string{"Environment", "Resources", "In Flight", "In Grace Period", "Non-Compliant", "Compliant (%)"}
+	if err := writer.Write(headers); err != nil {
+		return fmt.Errorf("error writing environment summary header: %w", err)
+	}
+
+	environments := make([]string, 0, len(s.tallies))
+	for environment := range s.tallies {
+		environments = append(environments, environment)
+	}
+	sort.Slice(environments, func(i, j int) bool {
+		a, b := s.tallies[environments[i]], s.tallies[environments[j]]
+		if a.resources != b.resources {
+			return a.resources > b.resources
+		}
+		return environments[i] < environments[j]
+	})
+
+	for _, environment := range environments {
+		t := s.tallies[environment]
+		percent := "N/A"
+		if checked := t.resources - t.inFlight - t.grace; checked > 0 {
+			percent = fmt.Sprintf("%.1f", float64(checked-t.nonCompliant)*100/float64(checked))
+		}
+		row := []string{environment, fmt.Sprintf("%d", t.resources), fmt.Sprintf("%d", t.inFlight), fmt.Sprintf("%d", t.grace), fmt.Sprintf("%d", t.nonCompliant), percent}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("error writing environment summary: %w", err)
+		}
+	}
+	return nil
+}
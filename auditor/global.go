@@ -0,0 +1,21 @@
+package auditor
+
+// globalResourceTypes are resource types that live in the home region and
+// are returned by a search in every subscribed region. With
+// -global-from-home-only they are only counted in the home region.
+var globalResourceTypes = map[string]bool{
+	"AuthenticationPolicy": true,
+	"Compartment":          true,
+	"DynamicGroup":         true,
+	"Group":                true,
+	"IdentityProvider":     true,
+	"NetworkSource":        true,
+	"Policy":               true,
+	"TagDefault":           true,
+	"TagNamespace":         true,
+	"User":                 true,
+}
+
+func isGlobalType(r ResourceSummary) bool {
+	return globalResourceTypes[getStringValue(r.ResourceType)]
+}
@@ -0,0 +1,93 @@
+package auditor
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// outputFile is an output file being written. With -gzip what is written
+// is compressed; Close ends the gzip stream and then closes the file, so
+// any writer buffering on top of it, such as a csv.Writer, must be flushed
+// before Close.
+type outputFile struct {
+	file   *os.File
+	gz     *gzip.Writer
+	closed bool
+}
+
+func newOutputFile(file *os.File, compress bool) *outputFile {
+	f := &outputFile{file: file}
+	if compress {
+		f.gz = gzip.NewWriter(file)
+	}
+	return f
+}
+
+func (f *outputFile) Write(p []byte) (int, error) {
+	if f.gz != nil {
+		return f.gz.Write(p)
+	}
+	return f.file.Write(p)
+}
+
+func (f *outputFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+// Flush writes the data compressed so far to the file, so a report flushed
+// after each page can be read up to that page.
+func (f *outputFile) Flush() error {
+	if f.gz != nil {
+		return f.gz.Flush()
+	}
+	return nil
+}
+
+// Close ends the gzip stream and closes the file. Only the first call has
+// an effect, so it can be deferred as well as called to check its error.
+func (f *outputFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	if f.gz != nil {
+		if err := f.gz.Close(); err != nil {
+			f.file.Close()
+			return err
+		}
+	}
+	return f.file.Close()
+}
+
+// gzipReader is a report opened for reading through a gzip reader.
+type gzipReader struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r *gzipReader) Close() error {
+	r.Reader.Close()
+	return r.file.Close()
+}
+
+// openReportReader opens a report written by an earlier run for reading,
+// whether or not it was written with -gzip, which is told by its .gz
+// extension.
+func openReportReader(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return file, nil
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return &gzipReader{Reader: reader, file: file}, nil
+}
@@ -0,0 +1,53 @@
+package auditor
+
+import "github.com/oracle/oci-go-sdk/v65/resourcesearch"
+
+// ResourceSummary is a single search result as returned by OCI.
+type ResourceSummary = resourcesearch.ResourceSummary
+
+// Hooks are optional callbacks invoked while a region is scanned, letting a
+// program running an Auditor add custom sinks, live counters or filtering
+// without forking the tool. They run after the region's own reports are
+// written, and after the run-wide reports such as -tag-coverage, which are
+// built on the same callbacks.
+//
+// Regions are scanned concurrently, so callbacks may run from multiple
+// goroutines at once and must be safe for concurrent use. Within a single
+// region they are called sequentially and in page order.
+type Hooks struct {
+	// OnResource is called for every resource written to the region's
+	// main report. Resources filtered out or failing to be written are not
+	// passed.
+	OnResource func(region string, r ResourceSummary)
+	// OnPage is called once per page of search results with the number of
+	// items on that page, after OnResource has run for each of them.
+	OnPage func(region string, pageItems int)
+}
+
+// chainHooks returns hooks that call each non-nil callback of the given
+// hooks in order.
+func chainHooks(hooks ...Hooks) Hooks {
+	var chained Hooks
+	for _, h := range hooks {
+		h := h
+		if h.OnResource != nil {
+			prev := chained.OnResource
+			chained.OnResource = func(region string, r ResourceSummary) {
+				if prev != nil {
+					prev(region, r)
+				}
+				h.OnResource(region, r)
+			}
+		}
+		if h.OnPage != nil {
+			prev := chained.OnPage
+			chained.OnPage = func(region string, pageItems int) {
+				if prev != nil {
+					prev(region, pageItems)
+				}
+				h.OnPage(region, pageItems)
+			}
+		}
+	}
+	return chained
+}
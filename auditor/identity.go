@@ -0,0 +1,82 @@
+package auditor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/identity"
+)
+
+// newIdentityClient creates an identity client for a config profile and
+// returns it with the profile's tenancy OCID.
+func (cfg *config) newIdentityClient(configPath, profile string) (identity.IdentityClient, string, error) {
+	provider, err := cfg.newConfigProvider(configPath, profile)
+	if err != nil {
+		return identity.IdentityClient{}, "", fmt.Errorf("failed to create configuration provider: %w", err)
+	}
+
+	idClient, err := identity.NewIdentityClientWithConfigurationProvider(provider)
+	if err != nil {
+		return identity.IdentityClient{}, "", fmt.Errorf("failed to create IdentityClient: %w", err)
+	}
+	cfg.setUserAgent(&idClient.BaseClient)
+
+	tenancyID, err := provider.TenancyOCID()
+	if err != nil {
+		return identity.IdentityClient{}, "", fmt.Errorf("failed to read tenancy OCID: %w", err)
+	}
+	return idClient, tenancyID, nil
+}
+
+// listCompartments returns every active compartment below the tenancy root,
+// following pagination. The root compartment itself is not included.
+func listCompartments(ctx context.Context, client identity.IdentityClient, tenancyID string) ([]identity.Compartment, error) {
+	request := identity.ListCompartmentsRequest{
+		CompartmentId:          common.String(tenancyID),
+		CompartmentIdInSubtree: common.Bool(true),
+		AccessLevel:            identity.ListCompartmentsAccessLevelAccessible,
+		LifecycleState:         identity.CompartmentLifecycleStateActive,
+		Limit:                  common.Int(1000),
+	}
+
+	var compartments []identity.Compartment
+	for {
+		response, err := client.ListCompartments(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("ListCompartments call failed: %w", err)
+		}
+		compartments = append(compartments, response.Items...)
+
+		if response.OpcNextPage == nil {
+			break
+		}
+		request.Page = response.OpcNextPage
+	}
+	return compartments, nil
+}
+
+// listTagNamespaces returns every tag namespace in the tenancy, including
+// retired ones, following pagination.
+func listTagNamespaces(ctx context.Context, client identity.IdentityClient, tenancyID string) ([]identity.TagNamespaceSummary, error) {
+	request := identity.ListTagNamespacesRequest{
+		CompartmentId:          common.String(tenancyID),
+		IncludeSubcompartments: common.Bool(true),
+		Limit:                  common.Int(1000),
+	}
+
+	var namespaces []identity.TagNamespaceSummary
+	for {
+		response, err := client.ListTagNamespaces(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("ListTagNamespaces call failed: %w", err)
+		}
+		namespaces = append(namespaces, response.Items...)
+
+		if response.OpcNextPage == nil {
+			break
+		}
+		request.Page = response.OpcNextPage
+	}
+	return namespaces, nil
+}
@@ -0,0 +1,95 @@
+package auditor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RunIndex lists the objects a run produces, so a consumer of a bucket can
+// discover every artifact of a run from one object. It is built from the
+// manifest and written next to it as index_<timestamp>.json.
+type RunIndex struct {
+	RunTimestamp string   `json:"run_timestamp"`
+	Manifest     string   `json:"manifest"`
+	Archive      string   `json:"archive,omitempty"`
+	Objects      []string `json:"objects"`
+}
+
+// latestPointer names the index of the most recent run. It is rewritten by
+// every run as latest.json.
+type latestPointer struct {
+	RunTimestamp string `json:"run_timestamp"`
+	Index        string `json:"index"`
+}
+
+// objectName returns the object name an output file is stored under: its
+// path below the data directory, date partition included.
+func (run *auditRun) objectName(path string) string {
+	rel, err := filepath.Rel(run.cfg.dataDir, path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+	return run.cfg.objectPrefix + filepath.ToSlash(rel)
+}
+
+// artifacts returns the paths of the files a run leaves behind, apart from
+// the manifest: the archive and its checksum, and the output files unless
+// -archive-cleanup removed them and they are only reachable through the
+// archive.
+func (run *auditRun) artifacts() []string {
+	run.manifest.mu.Lock()
+	defer run.manifest.mu.Unlock()
+
+	var paths []string
+	if run.manifest.Archive != "" {
+		paths = append(paths, run.manifest.Archive)
+		if _, ok := run.manifest.Checksums[run.manifest.Archive]; ok {
+			paths = append(paths, run.manifest.Archive+".sha256")
+		}
+	}
+	if run.manifest.Archive == "" || !run.cfg.archiveCleanup {
+		paths = append(paths, run.manifest.Files...)
+	}
+	return paths
+}
+
+// writeIndex writes the run index and the latest pointer for the manifest
+// at manifestPath.
+func (run *auditRun) writeIndex(manifestPath string) error {
+	index := RunIndex{
+		RunTimestamp: run.timestamp,
+		Manifest:     run.objectName(manifestPath),
+		Objects:      []string{},
+	}
+	run.manifest.mu.Lock()
+	archive := run.manifest.Archive
+	run.manifest.mu.Unlock()
+	if archive != "" {
+		index.Archive = run.objectName(archive)
+	}
+	for _, path := range run.artifacts() {
+		index.Objects = append(index.Objects, run.objectName(path))
+	}
+	index.Objects = append(index.Objects, index.Manifest)
+
+	indexPath := run.outputPath(fmt.Sprintf("index_%s.json", run.timestamp))
+	if err := writeJSON(indexPath, index); err != nil {
+		return fmt.Errorf("error writing run index: %w", err)
+	}
+
+	latest := latestPointer{RunTimestamp: run.timestamp, Index: run.objectName(indexPath)}
+	if err := writeJSON(run.rootPath("latest.json"), latest); err != nil {
+		return fmt.Errorf("error writing latest pointer: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(path string, v interface{}) error {
+	bytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(bytes, '\n'), 0644)
+}
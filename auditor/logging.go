@@ -0,0 +1,32 @@
+package auditor
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// setupLogging installs the -log-level and -log-format handler as the
+// default slog logger. Per-region progress is logged at debug level,
+// results and summaries at info, recoverable API and data issues at warn,
+// and failures at error.
+func (cfg *config) setupLogging() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.logLevel)); err != nil {
+		return fmt.Errorf("unknown -log-level %q, expected debug, info, warn or error", cfg.logLevel)
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.logFormat) {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, options)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	default:
+		return fmt.Errorf("unknown -log-format %q, expected text or json", cfg.logFormat)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
@@ -0,0 +1,220 @@
+package auditor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/resourcesearch"
+)
+
+// stringsFlag is a flag.Value collecting every occurrence of a repeatable
+// flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, splitList(value)...)
+	return nil
+}
+
+// ocidRegion returns the region encoded in an OCID of the form
+// ocid1.<type>.<realm>.<region>.<unique>. Global resources have an empty
+// region segment and return false.
+func ocidRegion(ocid string) (string, bool) {
+	parts := strings.Split(ocid, ".")
+	if len(parts) < 5 || parts[3] == "" {
+		return "", false
+	}
+	return string(common.StringToRegion(parts[3])), true
+}
+
+// newSearchClient creates a resource search client for a config section. A
+// non-empty region overrides the section's region key.
+func (cfg *config) newSearchClient(configPath, section, region string) (resourcesearch.ResourceSearchClient, string, error) {
+	configProvider, err := cfg.newConfigProvider(configPath, section)
+	if err != nil {
+		return resourcesearch.ResourceSearchClient{}, "", fmt.Errorf("error creating configuration provider: %w", err)
+	}
+
+	client, err := resourcesearch.NewResourceSearchClientWithConfigurationProvider(configProvider)
+	if err != nil {
+		return resourcesearch.ResourceSearchClient{}, "", fmt.Errorf("error creating search client: %w", err)
+	}
+	cfg.setUserAgent(&client.BaseClient)
+
+	if region != "" {
+		client.SetRegion(region)
+		return client, string(common.StringToRegion(region)), nil
+	}
+	region, err = configProvider.Region()
+	if err != nil {
+		return resourcesearch.ResourceSearchClient{}, "", fmt.Errorf("error reading region: %w", err)
+	}
+	// Config files may use short region keys such as "iad".
+	return client, string(common.StringToRegion(region)), nil
+}
+
+// identifierQuery builds a structured search query matching any of the
+// given OCIDs.
+func identifierQuery(ocids []string) string {
+	conditions := make([]string, len(ocids))
+	for i, ocid := range ocids {
+		conditions[i] = fmt.Sprintf("identifier = '%s'", strings.ReplaceAll(ocid, "'", ""))
+	}
+	return "query all resources where " + strings.Join(conditions, " || ")
+}
+
+// lookupBatchSize bounds the number of OCIDs per query to keep queries short.
+const lookupBatchSize = 50
+
+// searchIdentifiers searches one section for the given OCIDs and returns
+// the resources found, keyed by OCID.
+func searchIdentifiers(ctx context.Context, client SearchClient, ocids []string) (map[string]ResourceSummary, error) {
+	found := make(map[string]ResourceSummary)
+	for start := 0; start < len(ocids); start += lookupBatchSize {
+		end := start + lookupBatchSize
+		if end > len(ocids) {
+			end = len(ocids)
+		}
+
+		request := resourcesearch.SearchResourcesRequest{
+			SearchDetails: resourcesearch.StructuredSearchDetails{
+				Query: common.String(identifierQuery(ocids[start:end])),
+			},
+			Limit: common.Int(1000),
+		}
+		for {
+			response, err := client.SearchResources(ctx, request)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range response.Items {
+				found[getStringValue(r.Identifier)] = r
+			}
+			if response.OpcNextPage == nil {
+				break
+			}
+			request.Page = response.OpcNextPage
+		}
+	}
+	return found, nil
+}
+
+// LookupResources reports the compliance of specific resources instead of
+// scanning everything. Each OCID is first searched for in the sections
+// targeting the region embedded in it, then in the remaining sections.
+// OCIDs found nowhere are reported as not found.
+func LookupResources(ctx context.Context, run *auditRun, configPath string, sections []string, ocids []string) error {
+	type target struct {
+		section string
+		region  string
+		client  resourcesearch.ResourceSearchClient
+	}
+
+	var targets []target
+	for _, section := range sections {
+		client, region, err := run.cfg.newSearchClient(configPath, section, "")
+		if err != nil {
+			slog.Warn("Skipping config section", "region", section, "error", err)
+			continue
+		}
+		targets = append(targets, target{section: section, region: region, client: client})
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("no usable config sections")
+	}
+
+	found := make(map[string]ResourceSummary)
+	foundIn := make(map[string]string)
+
+	search := func(t target, wanted []string) {
+		var pending []string
+		for _, ocid := range wanted {
+			if _, ok := found[ocid]; !ok {
+				pending = append(pending, ocid)
+			}
+		}
+		if len(pending) == 0 {
+			return
+		}
+		results, err := searchIdentifiers(ctx, t.client, pending)
+		if err != nil {
+			slog.Warn("Error searching resources", "region", t.section, "error", err)
+			return
+		}
+		for ocid, r := range results {
+			found[ocid] = r
+			foundIn[ocid] = t.section
+		}
+	}
+
+	// First pass: each OCID in the sections matching its embedded region.
+	for _, t := range targets {
+		var wanted []string
+		for _, ocid := range ocids {
+			if region, ok := ocidRegion(ocid); ok && region == t.region {
+				wanted = append(wanted, ocid)
+			}
+		}
+		search(t, wanted)
+	}
+	// Second pass: whatever is left, everywhere.
+	for _, t := range targets {
+		search(t, ocids)
+	}
+
+	columns := run.cfg.reportColumns()
+	headers := append(columnHeaders(columns), "Status", "Compliance")
+	report, err := run.openReport(run.outputPath(fmt.Sprintf("resource_lookup_%s.csv", run.timestamp)), headers)
+	if err != nil {
+		return fmt.Errorf("error creating resource lookup report: %w", err)
+	}
+	// Closing again after an error is harmless.
+	defer report.Close()
+
+	var notFound int
+	for _, ocid := range ocids {
+		r, ok := found[ocid]
+		if !ok {
+			notFound++
+			row := make([]string, len(columns))
+			for i, c := range columns {
+				if c.header == "Identifier" {
+					row[i] = ocid
+				}
+			}
+			if err := report.Write(append(row, "not found", "")); err != nil {
+				return fmt.Errorf("error writing resource lookup report: %w", err)
+			}
+			continue
+		}
+
+		compliance := "compliant"
+		if reasons := run.cfg.complianceReasons(r); len(reasons) > 0 {
+			details := make([]string, len(reasons))
+			for i, reason := range reasons {
+				details[i] = reason.details
+			}
+			compliance = strings.Join(details, "; ")
+		}
+		row := append(buildRow(columns, foundIn[ocid], r), "found", compliance)
+		if err := report.Write(row); err != nil {
+			return fmt.Errorf("error writing resource lookup report: %w", err)
+		}
+	}
+
+	// A failed flush or, with -gzip, a failed close leaves the report
+	// truncated.
+	if err := report.Close(); err != nil {
+		return fmt.Errorf("error closing resource lookup report: %w", err)
+	}
+
+	slog.Info("Resource lookup finished", "found", len(ocids)-notFound, "requested", len(ocids))
+	return nil
+}
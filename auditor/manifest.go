@@ -0,0 +1,488 @@
+package auditor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Manifest describes the output of a single run. It is written next to the
+// reports as manifest_<timestamp>.json.
+type Manifest struct {
+	RunTimestamp  string `json:"run_timestamp"`
+	SchemaVersion int    `json:"schema_version"`
+	// Kind is what the run did: runKindScan, or runKindLookup or
+	// runKindTagDefaults, which write reports without scanning.
+	Kind string `json:"kind"`
+	// Complete is set for a scan that covered every region and resource in
+	// scope: not truncated, without failed regions and not narrowed by
+	// -since, -min-age-days or -compartment-id. Only a complete scan can be
+	// the cutoff of a later -since-last-run.
+	Complete        bool              `json:"complete"`
+	StartedAt       time.Time         `json:"started_at"`
+	FinishedAt      time.Time         `json:"finished_at"`
+	SinceCutoff     *time.Time        `json:"since_cutoff,omitempty"`
+	Partition       string            `json:"partition,omitempty"`
+	RunDir          string            `json:"run_dir,omitempty"`
+	Truncated       bool              `json:"truncated"`
+	TruncatedReason string            `json:"truncated_reason,omitempty"`
+	Files           []string          `json:"files"`
+	Archive         string            `json:"archive,omitempty"`
+	Checksums       map[string]string `json:"checksums,omitempty"`
+	// PostHookFailures maps the files whose -post-hook failed to the error.
+	PostHookFailures map[string]string `json:"post_hook_failures,omitempty"`
+	// StrictJSONFailures counts, per region, the resources -strict-json
+	// rejected. A region listed here is incomplete.
+	StrictJSONFailures map[string]int `json:"strict_json_failures,omitempty"`
+	// RegionFailures maps the regions whose scan failed to the error. Their
+	// reports hold what was written before the failure.
+	RegionFailures map[string]string `json:"region_failures,omitempty"`
+
+	mu sync.Mutex
+}
+
+// AddFile records a generated file. It is safe for concurrent use.
+func (m *Manifest) AddFile(path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Files = append(m.Files, path)
+}
+
+func (m *Manifest) Write(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bytes, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding manifest: %w", err)
+	}
+	if err := os.WriteFile(path, append(bytes, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing manifest: %w", err)
+	}
+	return nil
+}
+
+// addRegionFailure records a region whose scan failed. It is safe for
+// concurrent use.
+func (m *Manifest) addRegionFailure(section string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.RegionFailures == nil {
+		m.RegionFailures = make(map[string]string)
+	}
+	m.RegionFailures[section] = err.Error()
+}
+
+// failed reports whether a region failed or had -strict-json failures.
+func (m *Manifest) failed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.RegionFailures) > 0 || len(m.StrictJSONFailures) > 0
+}
+
+// regionFailures returns the failed regions, sorted, with their errors.
+func (m *Manifest) regionFailures() ([]string, map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	regions := make([]string, 0, len(m.RegionFailures))
+	for region := range m.RegionFailures {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	return regions, m.RegionFailures
+}
+
+// The kinds of run recorded in the manifest.
+const (
+	runKindScan        = "scan"
+	runKindLookup      = "lookup"
+	runKindTagDefaults = "tag-defaults"
+)
+
+// auditRun holds the state shared by every region goroutine of one run.
+type auditRun struct {
+	cfg       *config
+	timestamp string
+	manifest  *Manifest
+
+	// filePrefix is prepended to every output file name, e.g. the tenancy
+	// name with -prefix-tenancy.
+	filePrefix string
+
+	// combined receives every region's main report rows with -combined.
+	combined *combinedWriter
+	// progress logs the running counts with -progress.
+	progress *progressTracker
+	// workbook holds the sheets of the -format xlsx reports.
+	workbook *xlsxWorkbook
+	// dedup collects every region's main report rows by OCID with -dedup.
+	dedup *dedupReport
+
+	// hooks aggregate run-wide reports across regions. They run after the
+	// per-region report writers and before the Auditor's Hooks.
+	hooks Hooks
+
+	// cancel stops all in-flight region work once the -max-total cap is
+	// reached.
+	cancel    context.CancelFunc
+	processed int64
+	truncated int32
+	// limited is set once a region stopped at -max-resources, pageLimited
+	// once one stopped at -max-pages, emptyLimited once one stopped at
+	// -max-consecutive-empty, and repeatStopped once a search returned the
+	// page just requested as the next one.
+	limited       int32
+	pageLimited   int32
+	emptyLimited  int32
+	repeatStopped int32
+
+	// since, when non-zero, limits the scan to resources created after it.
+	// sinceKnownOnly is set for -since, which leaves out resources without
+	// a creation time unless -include-unknown-age is set.
+	since          time.Time
+	sinceKnownOnly bool
+	// compartments, when set by -compartment-id, limits the reports to
+	// resources in these compartments.
+	compartments map[string]bool
+
+	// stopped, when set, is why the scan was stopped early by a signal or
+	// -timeout.
+	stopped string
+
+	// debugFound is set once the -debug-ocid resource has been dumped.
+	debugFound int32
+
+	// tenancies resolves each config section's tenancy and home region.
+	tenancies *tenancyCache
+
+	// ociLog receives per-region summaries with -oci-logging-id.
+	ociLog *ociLogger
+
+	// typeFileSlots bounds the per-type files open at once across all
+	// regions with -split-by-type.
+	typeFileSlots chan struct{}
+
+	// limiter adapts the number of search requests in flight with
+	// -adaptive-concurrency.
+	limiter *aimdLimiter
+
+	// partition is the Hive-style date directory of the data directory
+	// this run writes to with -partition-by-date, e.g.
+	// "year=2024/month=05/day=17".
+	partition string
+	// runDir, with -run-dir, is the run's own directory below the
+	// partition, "run_<timestamp>".
+	runDir string
+}
+
+func newAuditRun(cfg *config, cancel context.CancelFunc) *auditRun {
+	now := time.Now().UTC()
+	timestamp := now.Format("20060102_150405")
+	return &auditRun{
+		cfg:       cfg,
+		timestamp: timestamp,
+		manifest:  &Manifest{RunTimestamp: timestamp, SchemaVersion: schemaVersion, Kind: runKindScan, StartedAt: now, Files: []string{}},
+		cancel:    cancel,
+		tenancies: newTenancyCache(cfg),
+	}
+}
+
+// lastRunStart returns the start time recorded in the most recent manifest
+// of a complete scan in the data directory. The manifests act as the run
+// history, so this is what -since-last-run scopes the scan to. Later runs
+// that were partial or scanned nothing are passed over: resources created
+// since the complete scan may not have been audited by them. Manifests
+// written before runs recorded whether they were complete do not count.
+func (run *auditRun) lastRunStart() (time.Time, bool, error) {
+	paths, err := run.historyGlob("manifest_*.json")
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	for i := len(paths) - 1; i >= 0; i-- {
+		bytes, err := os.ReadFile(paths[i])
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("error reading %s: %w", paths[i], err)
+		}
+		var previous Manifest
+		if err := json.Unmarshal(bytes, &previous); err != nil {
+			return time.Time{}, false, fmt.Errorf("error parsing %s: %w", paths[i], err)
+		}
+		if !previous.Complete {
+			slog.Debug("Passing over a partial run for -since-last-run", "path", paths[i], "kind", previous.Kind, "truncated_reason", previous.TruncatedReason)
+			continue
+		}
+		if previous.StartedAt.IsZero() {
+			return time.Time{}, false, fmt.Errorf("%s has no start time", paths[i])
+		}
+		return previous.StartedAt, true, nil
+	}
+	return time.Time{}, false, nil
+}
+
+// setSince scopes the run to resources created after cutoff and records the
+// cutoff in the manifest.
+func (run *auditRun) setSince(cutoff time.Time) {
+	run.since = cutoff
+	run.manifest.SinceCutoff = &cutoff
+}
+
+// include reports whether a resource falls inside the run's compartment
+// and creation-time scope. Resources without a creation time are included
+// by -since-last-run, since they cannot be shown to predate the cutoff, and
+// by -since and -min-age-days only with -include-unknown-age.
+func (run *auditRun) include(r ResourceSummary) bool {
+	if run.compartments != nil && !run.compartments[getStringValue(r.CompartmentId)] {
+		return false
+	}
+	if run.cfg.minAgeDays > 0 {
+		days, known := daysSinceCreation(r.TimeCreated)
+		if !known {
+			return run.cfg.includeUnknownAge
+		}
+		if days < run.cfg.minAgeDays {
+			return false
+		}
+	}
+	if run.since.IsZero() {
+		return true
+	}
+	if r.TimeCreated == nil {
+		return !run.sinceKnownOnly || run.cfg.includeUnknownAge
+	}
+	return r.TimeCreated.Time.After(run.since)
+}
+
+// setPartitionByDate makes the run write below the date partition of its
+// start time and records the partition in the manifest.
+func (run *auditRun) setPartitionByDate() {
+	run.partition = run.manifest.StartedAt.Format("year=2006/month=01/day=02")
+	run.manifest.Partition = run.partition
+}
+
+// setRunDir makes the run write into a directory of its own.
+func (run *auditRun) setRunDir() {
+	run.runDir = "run_" + run.timestamp
+	run.manifest.RunDir = run.runDir
+}
+
+// outputDir returns the directory this run writes to.
+func (run *auditRun) outputDir() string {
+	return filepath.Join(run.cfg.dataDir, filepath.FromSlash(run.partition), run.runDir)
+}
+
+// outputPath returns the path of an output file of this run, applying the
+// -prefix-tenancy file name prefix and the -partition-by-date and -run-dir
+// directories.
+func (run *auditRun) outputPath(name string) string {
+	return filepath.Join(run.outputDir(), run.filePrefix+name)
+}
+
+// rootPath returns the path of a file at the top of the data directory,
+// outside any partition.
+func (run *auditRun) rootPath(name string) string {
+	return filepath.Join(run.cfg.dataDir, run.filePrefix+name)
+}
+
+// historyGlob returns the files of earlier runs matching a file name
+// pattern, at the top of the data directory or in any date partition, and
+// in either directly or in a run directory, oldest first. File names embed
+// a sortable UTC timestamp, so they are ordered by name regardless of their
+// directory.
+func (run *auditRun) historyGlob(pattern string) ([]string, error) {
+	var paths []string
+	for _, dir := range []string{
+		run.cfg.dataDir,
+		filepath.Join(run.cfg.dataDir, "run_*"),
+		filepath.Join(run.cfg.dataDir, "year=*", "month=*", "day=*"),
+		filepath.Join(run.cfg.dataDir, "year=*", "month=*", "day=*", "run_*"),
+	} {
+		matches, err := filepath.Glob(filepath.Join(dir, run.filePrefix+pattern))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Slice(paths, func(i, j int) bool { return filepath.Base(paths[i]) < filepath.Base(paths[j]) })
+	return paths, nil
+}
+
+// reportPath returns the path of a per-section report of the given kind.
+func (run *auditRun) reportPath(section, kind string) string {
+	return run.outputPath(fmt.Sprintf("%s_%s_%s.csv", section, kind, run.timestamp))
+}
+
+// createReport creates a report file, and its directory if needed, and
+// records it in the manifest. With -gzip the file is compressed and .gz is
+// appended to its path. With -dry-run nothing is created: the path is
+// logged and the rows are written to the null device.
+func (run *auditRun) createReport(path string) (*outputFile, error) {
+	return run.createFile(path, run.cfg.gzipOutput)
+}
+
+// createFile is createReport for files that are compressed only when
+// compress is set: the metrics file and the workbook are always written
+// uncompressed for the programs that read them.
+func (run *auditRun) createFile(path string, compress bool) (*outputFile, error) {
+	if compress {
+		path += ".gz"
+	}
+	if run.cfg.dryRun {
+		slog.Info("Dry run: would write file", "path", path)
+		file, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			return nil, err
+		}
+		return newOutputFile(file, false), nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("error creating output directory: %w", err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	run.manifest.AddFile(path)
+	return newOutputFile(file, compress), nil
+}
+
+// reserve claims one slot of the tenancy-wide -max-total budget. It returns
+// false, and cancels the remaining region work, once the cap is reached.
+func (run *auditRun) reserve() bool {
+	if run.cfg.maxTotal <= 0 {
+		return true
+	}
+	if atomic.AddInt64(&run.processed, 1) <= int64(run.cfg.maxTotal) {
+		return true
+	}
+	if atomic.CompareAndSwapInt32(&run.truncated, 0, 1) {
+		run.cancel()
+	}
+	return false
+}
+
+// unreserve returns a slot claimed by reserve for a resource that was not
+// written.
+func (run *auditRun) unreserve() {
+	if run.cfg.maxTotal > 0 {
+		atomic.AddInt64(&run.processed, -1)
+	}
+}
+
+func (run *auditRun) isTruncated() bool {
+	return atomic.LoadInt32(&run.truncated) == 1
+}
+
+func (run *auditRun) markLimited() {
+	atomic.StoreInt32(&run.limited, 1)
+}
+
+func (run *auditRun) markPageLimited() {
+	atomic.StoreInt32(&run.pageLimited, 1)
+}
+
+func (run *auditRun) markEmptyLimited() {
+	atomic.StoreInt32(&run.emptyLimited, 1)
+}
+
+func (run *auditRun) markRepeatStopped() {
+	atomic.StoreInt32(&run.repeatStopped, 1)
+}
+
+// dumpResource writes the raw search result of a resource, and the request
+// ID of the page it came from, to stderr as indented JSON.
+func (run *auditRun) dumpResource(section string, opcRequestID *string, r ResourceSummary) {
+	atomic.StoreInt32(&run.debugFound, 1)
+
+	dump := struct {
+		Region       string          `json:"region"`
+		OpcRequestId string          `json:"opcRequestId"`
+		Resource     ResourceSummary `json:"resource"`
+	}{section, getStringValue(opcRequestID), r}
+
+	bytes, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		slog.Error("Error encoding resource for -debug-ocid", "ocid", getStringValue(r.Identifier), "error", err)
+		return
+	}
+	fmt.Fprintln(os.Stderr, string(bytes))
+}
+
+func (run *auditRun) debugSeen() bool {
+	return atomic.LoadInt32(&run.debugFound) == 1
+}
+
+// truncation describes a limit that cut a scan short, named by its flag, or
+// "repeat" for a search that returned its page token as the next one.
+func (cfg *config) truncation(limit string) string {
+	switch limit {
+	case "max-total":
+		return fmt.Sprintf("-max-total limit of %d resources reached", cfg.maxTotal)
+	case "max-resources":
+		return fmt.Sprintf("-max-resources limit of %d resources per region reached", cfg.maxResources)
+	case "max-pages":
+		return fmt.Sprintf("-max-pages limit of %d pages per region reached", cfg.maxPages)
+	case "max-consecutive-empty":
+		return fmt.Sprintf("-max-consecutive-empty limit of %d empty pages in a row reached", cfg.maxConsecutiveEmpty)
+	case "repeat":
+		return "a search returned the page just requested as the next page"
+	}
+	return limit
+}
+
+// truncatedReason returns why the run's reports are partial, or "" when
+// every region scanned to its last page.
+func (run *auditRun) truncatedReason() string {
+	switch {
+	case run.isTruncated():
+		return run.cfg.truncation("max-total")
+	case run.stopped != "":
+		return run.stopped
+	case atomic.LoadInt32(&run.limited) == 1:
+		return run.cfg.truncation("max-resources")
+	case atomic.LoadInt32(&run.pageLimited) == 1:
+		return run.cfg.truncation("max-pages")
+	case atomic.LoadInt32(&run.emptyLimited) == 1:
+		return run.cfg.truncation("max-consecutive-empty")
+	case atomic.LoadInt32(&run.repeatStopped) == 1:
+		return run.cfg.truncation("repeat")
+	}
+	return ""
+}
+
+// finish stamps the manifest and writes it to the data directory.
+func (run *auditRun) finish() error {
+	if run.cfg.dryRun {
+		slog.Info("Dry run: would write file", "path", run.outputPath(fmt.Sprintf("manifest_%s.json", run.timestamp)))
+		return nil
+	}
+	run.manifest.FinishedAt = time.Now().UTC()
+	if reason := run.truncatedReason(); reason != "" {
+		run.manifest.Truncated = true
+		run.manifest.TruncatedReason = reason
+	}
+	run.manifest.Complete = run.manifest.Kind == runKindScan && !run.manifest.Truncated && !run.manifest.failed() &&
+		!run.sinceKnownOnly && run.cfg.minAgeDays == 0 && run.compartments == nil
+	if run.cfg.checksums {
+		if err := run.checksumPending(); err != nil {
+			return err
+		}
+	}
+	path := run.outputPath(fmt.Sprintf("manifest_%s.json", run.timestamp))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+	if err := run.manifest.Write(path); err != nil {
+		return err
+	}
+	if run.cfg.writeIndex {
+		return run.writeIndex(path)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package auditor
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLastRunStartSkipsPartialRuns(t *testing.T) {
+	dir := t.TempDir()
+	run := newAuditRun(testConfig(t, "-output-dir", dir), func() {})
+	start := time.Date(2024, 5, 17, 8, 0, 0, 0, time.UTC)
+	write := func(hours int, m *Manifest) {
+		t.Helper()
+		m.StartedAt = start.Add(time.Duration(hours) * time.Hour)
+		m.RunTimestamp = m.StartedAt.Format("20060102_150405")
+		if err := m.Write(filepath.Join(dir, fmt.Sprintf("manifest_%s.json", m.RunTimestamp))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, found, err := run.lastRunStart(); err != nil || found {
+		t.Fatalf("no history: found %v, %v", found, err)
+	}
+
+	write(0, &Manifest{Kind: runKindScan, Complete: true})
+	write(1, &Manifest{Kind: runKindScan, Truncated: true, TruncatedReason: "-max-total limit of 10 resources reached"})
+	write(2, &Manifest{Kind: runKindScan, RegionFailures: map[string]string{"PHX": "search failed"}})
+	write(3, &Manifest{Kind: runKindLookup})
+	// A manifest from before runs recorded whether they were complete.
+	write(4, &Manifest{})
+
+	cutoff, found, err := run.lastRunStart()
+	if err != nil || !found || !cutoff.Equal(start) {
+		t.Errorf("lastRunStart = %v, %v, %v, want the complete scan's %v", cutoff, found, err, start)
+	}
+}
+
+func TestLastRunStartWithoutCompleteScan(t *testing.T) {
+	dir := t.TempDir()
+	run := newAuditRun(testConfig(t, "-output-dir", dir), func() {})
+	partial := Manifest{RunTimestamp: "20240517_080000", Kind: runKindTagDefaults, StartedAt: time.Now()}
+	if err := partial.Write(filepath.Join(dir, "manifest_20240517_080000.json")); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, err := run.lastRunStart(); err != nil || found {
+		t.Errorf("found %v, %v, want a full scan", found, err)
+	}
+}
+
+func TestFinishRecordsCompleteScan(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		kind     string
+		failure  bool
+		complete bool
+	}{
+		{"full scan", nil, runKindScan, false, true},
+		{"lookup", nil, runKindLookup, false, false},
+		{"failed region", nil, runKindScan, true, false},
+		{"narrowed by age", []string{"-min-age-days", "7"}, runKindScan, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := newAuditRun(testConfig(t, append([]string{"-output-dir", t.TempDir()}, tt.args...)...), func() {})
+			run.manifest.Kind = tt.kind
+			if tt.failure {
+				run.manifest.addRegionFailure("PHX", fmt.Errorf("search failed"))
+			}
+			if err := run.finish(); err != nil {
+				t.Fatal(err)
+			}
+			if run.manifest.Complete != tt.complete {
+				t.Errorf("complete = %v, want %v", run.manifest.Complete, tt.complete)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package auditor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// metricsText renders the run summary in the Prometheus text exposition
+// format, for the node_exporter textfile collector. Every gauge is labelled
+// by region; regions that failed have no counts and are reported by
+// oci_tag_audit_region_failed instead.
+func (s *runSummary) metricsText(run *auditRun) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	regions := make([]string, 0, len(s.regions))
+	for region := range s.regions {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	var b strings.Builder
+	gauges := []struct {
+		name, help string
+		value      func(c tallyCounts) int
+	}{
+		{"oci_tag_audit_resources_total", "Resources reported for the region.", func(c tallyCounts) int { return c.resources }},
+		{"oci_tag_audit_missing_tags_total", "Resources of the region with missing tags.", func(c tallyCounts) int { return c.missingTags }},
+		{"oci_tag_audit_no_owner_total", "Resources of the region with no owner.", func(c tallyCounts) int { return c.noOwner }},
+	}
+	for _, g := range gauges {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+		for _, region := range regions {
+			fmt.Fprintf(&b, "%s{region=\"%s\"} %d\n", g.name, metricLabel(region), g.value(s.regions[region].counts))
+		}
+	}
+
+	failed, _ := run.manifest.regionFailures()
+	b.WriteString("# HELP oci_tag_audit_region_failed Whether the region's scan failed.\n# TYPE oci_tag_audit_region_failed gauge\n")
+	for _, region := range regions {
+		fmt.Fprintf(&b, "oci_tag_audit_region_failed{region=\"%s\"} 0\n", metricLabel(region))
+	}
+	for _, region := range failed {
+		fmt.Fprintf(&b, "oci_tag_audit_region_failed{region=\"%s\"} 1\n", metricLabel(region))
+	}
+
+	b.WriteString("# HELP oci_tag_audit_last_run_timestamp_seconds Start time of the audit run.\n# TYPE oci_tag_audit_last_run_timestamp_seconds gauge\n")
+	fmt.Fprintf(&b, "oci_tag_audit_last_run_timestamp_seconds %d\n", run.manifest.StartedAt.Unix())
+	return b.String()
+}
+
+// metricLabel escapes a label value for the text format.
+func metricLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// WriteMetrics writes metrics_<timestamp>.prom with the run's gauges and,
+// with -metrics-file, replaces that file with the same content. The file is
+// written next to it and renamed, so a collector never reads it half
+// written.
+func (s *runSummary) WriteMetrics(run *auditRun) error {
+	text := s.metricsText(run)
+
+	file, err := run.createFile(run.outputPath(fmt.Sprintf("metrics_%s.prom", run.timestamp)), false)
+	if err != nil {
+		return fmt.Errorf("error creating metrics file: %w", err)
+	}
+	if _, err := file.WriteString(text); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing metrics file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error writing metrics file: %w", err)
+	}
+
+	if run.cfg.metricsFile == "" || run.cfg.dryRun {
+		return nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(run.cfg.metricsFile), ".oci_tag_audit_*.prom")
+	if err != nil {
+		return fmt.Errorf("error writing %s: %w", run.cfg.metricsFile, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(text); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing %s: %w", run.cfg.metricsFile, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing %s: %w", run.cfg.metricsFile, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("error writing %s: %w", run.cfg.metricsFile, err)
+	}
+	if err := os.Rename(tmp.Name(), run.cfg.metricsFile); err != nil {
+		return fmt.Errorf("error writing %s: %w", run.cfg.metricsFile, err)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package auditor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// searchTarget is one search of a run: a config profile, optionally in a
+// region other than the profile's own, and the label of its output.
+type searchTarget struct {
+	configPath string
+	profile    string
+	region     string
+	label      string
+	// prefix is prepended to the section or region name of unlabeled
+	// targets when several -config files are audited.
+	prefix string
+}
+
+// name is the target's name in logs and failures before its region is
+// known.
+func (t searchTarget) name() string {
+	if t.label != "" {
+		return t.prefix + t.label
+	}
+	return t.prefix + t.profile
+}
+
+// tenancyEntry is one line of a -tenancies-file.
+type tenancyEntry struct {
+	tenancyID string
+	profile   string
+	regions   []string
+}
+
+// loadTenanciesFile reads a CSV of tenancy OCID, profile name and optional
+// regions separated by ";". A first line that does not start with an
+// OCID is taken as a header.
+func loadTenanciesFile(path string) ([]tenancyEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening tenancies file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading tenancies file %s: %w", path, err)
+	}
+	if len(records) > 0 && !strings.HasPrefix(strings.TrimSpace(records[0][0]), "ocid1.") {
+		records = records[1:]
+	}
+
+	var entries []tenancyEntry
+	seen := make(map[string]bool)
+	for i, record := range records {
+		if len(record) < 2 {
+			return nil, fmt.Errorf("tenancies file %s: line %d needs a tenancy OCID and a profile", path, i+1)
+		}
+		entry := tenancyEntry{
+			tenancyID: strings.TrimSpace(record[0]),
+			profile:   strings.TrimSpace(record[1]),
+		}
+		if !strings.HasPrefix(entry.tenancyID, "ocid1.tenancy.") || entry.profile == "" {
+			return nil, fmt.Errorf("tenancies file %s: invalid entry %q", path, strings.Join(record, ","))
+		}
+		if seen[entry.tenancyID] {
+			return nil, fmt.Errorf("tenancies file %s: tenancy %s is listed twice", path, entry.tenancyID)
+		}
+		seen[entry.tenancyID] = true
+		if len(record) > 2 {
+			for _, region := range strings.Split(record[2], ";") {
+				if region = strings.TrimSpace(region); region != "" {
+					entry.regions = append(entry.regions, region)
+				}
+			}
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("tenancies file %s lists no tenancies", path)
+	}
+	return entries, nil
+}
+
+// targets returns one search per listed region of the entry, or a single
+// search in the profile's own region. Output is labeled
+// <profile>_<region> so every tenancy's files stay apart.
+func (e tenancyEntry) targets(configPath, profileRegion string) []searchTarget {
+	regions := e.regions
+	if len(regions) == 0 {
+		regions = []string{profileRegion}
+	}
+	targets := make([]searchTarget, len(regions))
+	for i, region := range regions {
+		targets[i] = searchTarget{configPath: configPath, profile: e.profile, region: region, label: e.profile + "_" + region}
+	}
+	return targets
+}
+
+// tenancyRollup summarizes each tenancy of a -tenancies-file run. It is fed
+// by an OnResource hook and is safe for concurrent use.
+type tenancyRollup struct {
+	cfg *config
+	// byLabel maps a search label to its tenancy entry.
+	byLabel map[string]tenancyEntry
+
+	mu      sync.Mutex
+	tallies map[string]*rollupTally
+}
+
+type rollupTally struct {
+	regions      map[string]bool
+	resources    int
+	nonCompliant int
+}
+
+func newTenancyRollup(cfg *config, entries []tenancyEntry, targets map[string][]searchTarget) *tenancyRollup {
+	rollup := &tenancyRollup{cfg: cfg, byLabel: make(map[string]tenancyEntry), tallies: make(map[string]*rollupTally)}
+	for _, entry := range entries {
+		rollup.tallies[entry.tenancyID] = &rollupTally{regions: make(map[string]bool)}
+		for _, target := range targets[entry.tenancyID] {
+			rollup.byLabel[target.label] = entry
+		}
+	}
+	return rollup
+}
+
+func (t *tenancyRollup) onResource(label string, r ResourceSummary) {
+	entry, ok := t.byLabel[label]
+	if !ok {
+		return
+	}
+	nonCompliant := !t.cfg.exemptFromChecks(r) && len(t.cfg.complianceReasons(r)) > 0
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tally := t.tallies[entry.tenancyID]
+	tally.regions[label] = true
+	tally.resources++
+	if nonCompliant {
+		tally.nonCompliant++
+	}
+}
+
+// Write writes one line per tenancy, least compliant first, and a total.
+func (t *tenancyRollup) Write(run *auditRun, entries []tenancyEntry) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	file, err := run.createReport(run.outputPath(fmt.Sprintf("tenancy_rollup_%s.csv", run.timestamp)))
+	if err != nil {
+		return fmt.Errorf("error creating tenancy roll-up: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	if err := writer.Write([]string{"Tenancy ID", "Tenancy Name", "Profile", "Regions Scanned", "Resources", "Non-Compliant", "Compliant (%)"}); err != nil {
+		return fmt.Errorf("error writing tenancy roll-up header: %w", err)
+	}
+
+	percent := func(resources, nonCompliant int) string {
+		if resources == 0 {
+			return "N/A"
+		}
+		return fmt.Sprintf("%.1f", float64(resources-nonCompliant)*100/float64(resources))
+	}
+
+	sorted := append([]tenancyEntry{}, entries...)
+	share := func(e tenancyEntry) float64 {
+		tally := t.tallies[e.tenancyID]
+		if tally.resources == 0 {
+			return 0
+		}
+		return float64(tally.nonCompliant) / float64(tally.resources)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return share(sorted[i]) > share(sorted[j]) })
+
+	var resources, nonCompliant int
+	for _, entry := range sorted {
+		tally := t.tallies[entry.tenancyID]
+		resources += tally.resources
+		nonCompliant += tally.nonCompliant
+
+		row := []string{entry.tenancyID, run.tenancies.name(entry.tenancyID), entry.profile, fmt.Sprintf("%d", len(tally.regions)),
+			fmt.Sprintf("%d", tally.resources), fmt.Sprintf("%d", tally.nonCompliant), percent(tally.resources, tally.nonCompliant)}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("error writing tenancy roll-up: %w", err)
+		}
+	}
+
+	total := []string{"(all)", "", "", "", fmt.Sprintf("%d", resources), fmt.Sprintf("%d", nonCompliant), percent(resources, nonCompliant)}
+	if err := writer.Write(total); err != nil {
+		return fmt.Errorf("error writing tenancy roll-up: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package auditor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/loggingingestion"
+)
+
+// logBatchSize bounds the entries sent per PutLogs call, keeping requests
+// well below the ingestion size limit.
+const logBatchSize = 100
+
+// ociLogger sends compliance results to an OCI Logging custom log. Entries
+// are queued per region and type and sent in batches. A failed batch is
+// logged and dropped; it never stops the audit. It is safe for concurrent
+// use. Entries are sent with a background context, so results queued
+// before a -max-total stop still reach the log.
+type ociLogger struct {
+	cfg     *config
+	client  loggingingestion.LoggingClient
+	logID   string
+	tenancy TenancyInfo
+
+	mu      sync.Mutex
+	pending map[logBatchKey][]loggingingestion.LogEntry
+	nextID  int
+	sent    int
+	failed  int
+}
+
+type logBatchKey struct {
+	region string
+	kind   string
+}
+
+// newOCILogger creates a logging ingestion client from the DEFAULT profile,
+// which must be in the region of the custom log.
+func (cfg *config) newOCILogger(configPath, logID string, tenancy TenancyInfo) (*ociLogger, error) {
+	provider, err := cfg.newConfigProvider(configPath, "DEFAULT")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create configuration provider: %w", err)
+	}
+	client, err := loggingingestion.NewLoggingClientWithConfigurationProvider(provider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create LoggingClient: %w", err)
+	}
+	cfg.setUserAgent(&client.BaseClient)
+
+	return &ociLogger{
+		cfg:     cfg,
+		client:  client,
+		logID:   logID,
+		tenancy: tenancy,
+		pending: make(map[logBatchKey][]loggingingestion.LogEntry),
+	}, nil
+}
+
+// add queues one entry and sends its batch once it is full. The tenancy and
+// region are added to every entry's data.
+func (l *ociLogger) add(region, kind string, data map[string]interface{}) {
+	data["tenancy_id"] = l.tenancy.TenancyID
+	data["tenancy_name"] = l.tenancy.TenancyName
+	data["region"] = region
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("Error encoding OCI Logging entry", "kind", kind, "region", region, "error", err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.nextID++
+	key := logBatchKey{region: region, kind: kind}
+	l.pending[key] = append(l.pending[key], loggingingestion.LogEntry{
+		Data: common.String(string(bytes)),
+		Id:   common.String(fmt.Sprintf("%s-%d", l.tenancy.TenancyID, l.nextID)),
+		Time: &common.SDKTime{Time: time.Now().UTC()},
+	})
+	if len(l.pending[key]) >= logBatchSize {
+		l.send(key)
+	}
+}
+
+// send puts one pending batch. The caller holds l.mu.
+func (l *ociLogger) send(key logBatchKey) {
+	entries := l.pending[key]
+	delete(l.pending, key)
+	if len(entries) == 0 {
+		return
+	}
+
+	request := loggingingestion.PutLogsRequest{
+		LogId: common.String(l.logID),
+		PutLogsDetails: loggingingestion.PutLogsDetails{
+			Specversion: common.String("1.0"),
+			LogEntryBatches: []loggingingestion.LogEntryBatch{{
+				Entries:             entries,
+				Source:              common.String("oci-tag-auditor"),
+				Type:                common.String("oci-tag-auditor." + key.kind),
+				Subject:             common.String(key.region),
+				Defaultlogentrytime: &common.SDKTime{Time: time.Now().UTC()},
+			}},
+		},
+	}
+	if _, err := l.client.PutLogs(context.Background(), request); err != nil {
+		slog.Warn("Error sending entries to OCI Logging", "entries", len(entries), "kind", key.kind, "region", key.region, "error", err)
+		l.failed += len(entries)
+		return
+	}
+	l.sent += len(entries)
+}
+
+// onViolation is an OnResource hook that queues one entry per
+// non-compliant resource.
+func (l *ociLogger) onViolation(region string, r ResourceSummary) {
+	if l.cfg.exemptFromChecks(r) {
+		return
+	}
+	reasons := l.cfg.complianceReasons(r)
+	if len(reasons) == 0 {
+		return
+	}
+
+	var details []string
+	for _, reason := range reasons {
+		details = append(details, reason.id+": "+reason.details)
+	}
+	l.add(region, "violation", map[string]interface{}{
+		"identifier":     getStringValue(r.Identifier),
+		"resource_type":  getStringValue(r.ResourceType),
+		"compartment_id": getStringValue(r.CompartmentId),
+		"violations":     details,
+	})
+}
+
+// Close sends every remaining batch and logs the totals.
+func (l *ociLogger) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key := range l.pending {
+		l.send(key)
+	}
+	slog.Info("OCI Logging finished", "sent", l.sent, "failed", l.failed)
+}
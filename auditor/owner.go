@@ -0,0 +1,147 @@
+package auditor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// freeformTagValue looks up a freeform tag, matching the key
+// case-insensitively.
+func freeformTagValue(tags map[string]string, key string) (string, bool) {
+	for k, v := range tags {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// ownerStatus reports whether a resource has an owner. A defined CreatedBy
+// tag always counts; with -owner-freeform-key, the freeform tag counts too,
+// provided its value matches -owner-value-regex. Placeholder values count
+// as no owner in either. The note explains a malformed owner value and is
+// empty otherwise.
+func (cfg *config) ownerStatus(r ResourceSummary) (bool, string) {
+	if cfg.hasCreatedByTag(r.DefinedTags) {
+		return true, ""
+	}
+	if cfg.ownerFreeformKey == "" {
+		return false, ""
+	}
+
+	value, ok := freeformTagValue(r.FreeformTags, cfg.ownerFreeformKey)
+	if !ok || cfg.isPlaceholderOwner(value) {
+		return false, ""
+	}
+	if cfg.ownerValuePattern != nil && !cfg.ownerValuePattern.MatchString(value) {
+		return false, fmt.Sprintf("malformed owner: %s=%q does not match %s", cfg.ownerFreeformKey, value, cfg.ownerValuePattern)
+	}
+	if cfg.ownerValuePattern == nil && strings.TrimSpace(value) == "" {
+		return false, fmt.Sprintf("malformed owner: %s is empty", cfg.ownerFreeformKey)
+	}
+	return true, ""
+}
+
+// ownerSource returns where ownerStatus found the owner of a resource:
+// "defined" for the CreatedBy tag, "freeform" for the -owner-freeform-key
+// tag, or "none".
+func (cfg *config) ownerSource(r ResourceSummary) string {
+	if cfg.hasCreatedByTag(r.DefinedTags) {
+		return "defined"
+	}
+	if owned, _ := cfg.ownerStatus(r); owned {
+		return "freeform"
+	}
+	return "none"
+}
+
+// isOwnerValue reports whether a tag value names an owner: it is not empty
+// and not one of -owner-placeholders.
+func (cfg *config) isOwnerValue(value string) bool {
+	return value != "" && !cfg.isPlaceholderOwner(value)
+}
+
+func (cfg *config) isPlaceholderOwner(value string) bool {
+	return cfg.ownerPlaceholders[strings.ToLower(strings.TrimSpace(value))]
+}
+
+// ownerTagLabel names the owner tag in notes.
+func (cfg *config) ownerTagLabel() string {
+	if cfg.ownerTag != nil {
+		return cfg.ownerTag.name()
+	}
+	return ownerTagKey
+}
+
+// tagSource is one entry of -owner-equivalents: a defined tag
+// "Namespace.Key", a defined key in any namespace "*.Key", or a freeform
+// tag "freeform:Key".
+type tagSource struct {
+	freeform  bool
+	namespace string
+	key       string
+}
+
+func parseTagSources(list string) ([]tagSource, error) {
+	var sources []tagSource
+	for _, item := range splitList(list) {
+		if strings.HasPrefix(strings.ToLower(item), "freeform:") {
+			key := strings.TrimSpace(item[len("freeform:"):])
+			if key == "" {
+				return nil, fmt.Errorf("invalid owner equivalent %q, missing freeform key", item)
+			}
+			sources = append(sources, tagSource{freeform: true, key: key})
+			continue
+		}
+		namespace, key, err := parseTagRef(item)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, tagSource{namespace: namespace, key: key})
+	}
+	return sources, nil
+}
+
+// ownerValues returns the non-empty values of every configured source on a
+// resource, keyed by the tag they were read from.
+func ownerValues(r ResourceSummary, sources []tagSource) map[string]string {
+	values := make(map[string]string)
+	for _, src := range sources {
+		if src.freeform {
+			if v, ok := freeformTagValue(r.FreeformTags, src.key); ok && strings.TrimSpace(v) != "" {
+				values["freeform:"+src.key] = v
+			}
+			continue
+		}
+		for namespace, tags := range r.DefinedTags {
+			if src.namespace != "*" && !strings.EqualFold(namespace, src.namespace) {
+				continue
+			}
+			for k, v := range tags {
+				if s := fmt.Sprint(v); strings.EqualFold(k, src.key) && strings.TrimSpace(s) != "" {
+					values[namespace+"."+k] = s
+				}
+			}
+		}
+	}
+	return values
+}
+
+// ownerConflict reports whether the owner sources of a resource disagree,
+// ignoring case, and returns the sources and values involved.
+func ownerConflict(r ResourceSummary, sources []tagSource) (string, bool) {
+	values := ownerValues(r, sources)
+
+	distinct := make(map[string]bool)
+	var parts []string
+	for source, value := range values {
+		distinct[strings.ToLower(value)] = true
+		parts = append(parts, fmt.Sprintf("%s=%s", source, value))
+	}
+	if len(distinct) < 2 {
+		return "", false
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "; "), true
+}
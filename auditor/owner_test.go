@@ -0,0 +1,96 @@
+package auditor
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestOwnerStatusFreeform(t *testing.T) {
+	cfg := testConfig(t, "-owner-freeform-key", "owner", "-owner-value-regex", `^[^@\s]+@[^@\s]+$`, "-owner-placeholders", "unknown")
+	tests := []struct {
+		name         string
+		definedTags  string
+		freeformTags map[string]string
+		owned        bool
+		note         string
+		source       string
+	}{
+		{"valid", `{}`, map[string]string{"owner": "alice@corp.com"}, true, "", "freeform"},
+		{"key in another case", `{}`, map[string]string{"Owner": "alice@corp.com"}, true, "", "freeform"},
+		{"malformed", `{}`, map[string]string{"owner": "alice"}, false, `malformed owner: owner="alice" does not match ^[^@\s]+@[^@\s]+$`, "none"},
+		{"placeholder", `{}`, map[string]string{"owner": "unknown"}, false, "", "none"},
+		{"missing", `{}`, map[string]string{"team": "web"}, false, "", "none"},
+		{"defined owner wins", `{"Oracle-Tags":{"CreatedBy":"bob"}}`, map[string]string{"owner": "alice"}, true, "", "defined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testResource("ocid1.instance.a", tt.definedTags)
+			r.FreeformTags = tt.freeformTags
+			owned, note := cfg.ownerStatus(r)
+			if owned != tt.owned || note != tt.note {
+				t.Errorf("ownerStatus = %v, %q, want %v, %q", owned, note, tt.owned, tt.note)
+			}
+			if source := cfg.ownerSource(r); source != tt.source {
+				t.Errorf("ownerSource = %q, want %q", source, tt.source)
+			}
+		})
+	}
+}
+
+func TestOwnerStatusBlankWithoutRegex(t *testing.T) {
+	cfg := testConfig(t, "-owner-freeform-key", "owner")
+	r := testResource("ocid1.instance.a", `{}`)
+	r.FreeformTags = map[string]string{"owner": " "}
+	if owned, note := cfg.ownerStatus(r); owned || note != "malformed owner: owner is empty" {
+		t.Errorf("ownerStatus = %v, %q", owned, note)
+	}
+}
+
+func TestOwnerValueRegexNeedsFreeformKey(t *testing.T) {
+	cfg, fs := newConfig("test", flag.ContinueOnError)
+	if err := fs.Parse([]string{"-owner-value-regex", ".+@.+"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.loadChecks(); err == nil {
+		t.Error("-owner-value-regex without -owner-freeform-key was accepted")
+	}
+}
+
+func TestOwnerConflict(t *testing.T) {
+	sources, err := parseTagSources("*.CreatedBy,Ops.Owner,freeform:owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		definedTags  string
+		freeformTags map[string]string
+		want         string
+		conflict     bool
+	}{
+		{"single source", `{"Oracle-Tags":{"CreatedBy":"alice"}}`, nil, "", false},
+		{"agreeing sources", `{"Oracle-Tags":{"CreatedBy":"alice"},"Ops":{"Owner":"alice"}}`, map[string]string{"owner": "alice"}, "", false},
+		{"agreeing except case", `{"Oracle-Tags":{"CreatedBy":"Alice"}}`, map[string]string{"owner": "alice"}, "", false},
+		{"blank source ignored", `{"Oracle-Tags":{"CreatedBy":"alice"},"Ops":{"Owner":" "}}`, nil, "", false},
+		{"defined and freeform disagree", `{"Oracle-Tags":{"CreatedBy":"alice"}}`, map[string]string{"owner": "bob"}, "Oracle-Tags.CreatedBy=alice; freeform:owner=bob", true},
+		{"two CreatedBy namespaces disagree", `{"Oracle-Tags":{"CreatedBy":"alice"},"Ops":{"CreatedBy":"bob"}}`, nil, "Ops.CreatedBy=bob; Oracle-Tags.CreatedBy=alice", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testResource("ocid1.instance.a", tt.definedTags)
+			r.FreeformTags = tt.freeformTags
+			got, conflict := ownerConflict(r, sources)
+			if got != tt.want || conflict != tt.conflict {
+				t.Errorf("ownerConflict = %q, %v, want %q, %v", got, conflict, tt.want, tt.conflict)
+			}
+		})
+	}
+}
+
+func TestParseTagSources(t *testing.T) {
+	for _, list := range []string{"freeform:", "CreatedBy", "Ops."} {
+		if _, err := parseTagSources(list); err == nil {
+			t.Errorf("parseTagSources(%q) accepted an invalid source", list)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package auditor
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/resourcesearch"
+	"golang.org/x/time/rate"
+)
+
+// retryDelay is the base pause before retrying a failed page request.
+const retryDelay = 200 * time.Millisecond
+
+// SearchClient is the part of the resource search API a scan uses. It is
+// satisfied by resourcesearch.ResourceSearchClient; tests and embedding
+// programs can pass a fake returning canned pages.
+type SearchClient interface {
+	SearchResources(ctx context.Context, request resourcesearch.SearchResourcesRequest) (resourcesearch.SearchResourcesResponse, error)
+}
+
+type pageResult struct {
+	response resourcesearch.SearchResourcesResponse
+	err      error
+}
+
+// pager yields the pages of a search in order. By default each page is
+// fetched when asked for. With prefetch, a goroutine fetches the next page
+// while the caller processes the current one; there is still at most one
+// request in flight. Every request, retries included, waits for the
+// search's -rate limiter.
+type pager struct {
+	// cfg has the -rate, -max-retries, -max-pages and
+	// -max-consecutive-empty limits.
+	cfg *config
+	// section labels the search in retry logs.
+	section string
+	ctx     context.Context
+	client  SearchClient
+	request resourcesearch.SearchResourcesRequest
+	done    bool
+
+	// rate paces the requests of this search; nil with -rate 0.
+	rate *rate.Limiter
+
+	// limiter, when set, bounds requests across regions.
+	limiter *aimdLimiter
+
+	// pages and stop are only set with prefetch.
+	pages chan pageResult
+	stop  chan struct{}
+
+	// emptyPages counts the empty pages that still had a next page, and
+	// consecutiveEmpty the current run of them. stoppedEmpty is set once
+	// -max-consecutive-empty ended the search. They are written by fetch
+	// and may only be read once next has returned false or close has
+	// returned.
+	emptyPages       int
+	consecutiveEmpty int
+	stoppedEmpty     bool
+
+	// fetched counts the pages received. stoppedRepeat is set when a page
+	// named itself as the next one, and stoppedMaxPages when -max-pages
+	// ended the search. Like emptyPages, they are written by fetch.
+	fetched         int
+	stoppedRepeat   bool
+	stoppedMaxPages bool
+}
+
+func newPager(ctx context.Context, cfg *config, section string, client SearchClient, request resourcesearch.SearchResourcesRequest, prefetch bool, limiter *aimdLimiter) *pager {
+	p := &pager{cfg: cfg, section: section, ctx: ctx, client: client, request: request, limiter: limiter}
+	if cfg.requestRate > 0 {
+		p.rate = rate.NewLimiter(rate.Limit(cfg.requestRate), 1)
+	}
+	if prefetch {
+		p.pages = make(chan pageResult, 1)
+		p.stop = make(chan struct{})
+		go p.prefetch()
+	}
+	return p
+}
+
+// next returns the next page. It returns false once the last page has
+// been returned or after an error.
+func (p *pager) next() (resourcesearch.SearchResourcesResponse, bool, error) {
+	if p.pages != nil {
+		result, ok := <-p.pages
+		return result.response, ok, result.err
+	}
+
+	if p.done {
+		return resourcesearch.SearchResourcesResponse{}, false, nil
+	}
+
+	response, last, err := p.fetch()
+	p.done = last
+	return response, true, err
+}
+
+// fetch requests one page and advances the request to the page after it.
+// It reports whether there are no further pages. Search is eventually
+// consistent and may return an empty page that is not the last; more than
+// -max-consecutive-empty of those in a row end the search. Under heavy load
+// the API may also return the token of the page just requested as the next
+// one, which would request the same page forever, so that ends the search
+// too, as does reaching -max-pages.
+func (p *pager) fetch() (resourcesearch.SearchResourcesResponse, bool, error) {
+	response, err := p.search()
+	if err != nil {
+		return response, true, err
+	}
+	p.fetched++
+	if response.OpcNextPage == nil {
+		return response, true, nil
+	}
+	if p.request.Page != nil && *response.OpcNextPage == *p.request.Page {
+		p.stoppedRepeat = true
+		return response, true, nil
+	}
+	if p.cfg.maxPages > 0 && p.fetched >= p.cfg.maxPages {
+		p.stoppedMaxPages = true
+		return response, true, nil
+	}
+
+	if len(response.Items) == 0 {
+		p.emptyPages++
+		p.consecutiveEmpty++
+		if p.cfg.maxConsecutiveEmpty > 0 && p.consecutiveEmpty > p.cfg.maxConsecutiveEmpty {
+			p.stoppedEmpty = true
+			return response, true, nil
+		}
+	} else {
+		p.consecutiveEmpty = 0
+	}
+	p.request.Page = response.OpcNextPage
+	return response, false, nil
+}
+
+// search sends the current page request, through the limiter when set.
+// Transient failures are retried up to -max-retries times with
+// exponential backoff; permanent ones are returned at once.
+func (p *pager) search() (resourcesearch.SearchResourcesResponse, error) {
+	for attempt := 1; ; attempt++ {
+		if err := p.wait(); err != nil {
+			return resourcesearch.SearchResourcesResponse{}, err
+		}
+		if p.limiter != nil {
+			if err := p.limiter.acquire(p.ctx); err != nil {
+				return resourcesearch.SearchResourcesResponse{}, err
+			}
+		}
+		response, err := p.client.SearchResources(p.ctx, p.request)
+		if p.limiter != nil {
+			p.limiter.release(isThrottled(err))
+		}
+		if !isRetryable(p.ctx, err) || attempt > p.cfg.maxRetries {
+			return response, err
+		}
+
+		delay := backoff(retryDelay, attempt)
+		slog.Warn("Search request failed, retrying", "region", p.section, "error", err, "retry", attempt, "max_retries", p.cfg.maxRetries, "delay", delay.Round(time.Millisecond))
+		select {
+		case <-time.After(delay):
+		case <-p.ctx.Done():
+			return response, err
+		}
+	}
+}
+
+// wait blocks until the -rate limiter allows the next request.
+func (p *pager) wait() error {
+	if p.rate == nil {
+		return nil
+	}
+	return p.rate.Wait(p.ctx)
+}
+
+func (p *pager) prefetch() {
+	defer close(p.pages)
+	for {
+		response, last, err := p.fetch()
+		select {
+		case p.pages <- pageResult{response: response, err: err}:
+		case <-p.stop:
+			return
+		}
+		if last {
+			return
+		}
+
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+	}
+}
+
+// close stops a prefetching pager whose pages are no longer read and waits
+// for its goroutine to exit, finishing the request it has in flight. It may
+// be called more than once.
+func (p *pager) close() {
+	if p.stop == nil {
+		return
+	}
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+	}
+	for range p.pages {
+	}
+}
@@ -0,0 +1,302 @@
+package auditor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/resourcesearch"
+)
+
+// fakeSearch is a SearchClient serving pages of perPage instances. Page
+// tokens are page numbers; the first page has none. After pages pages the
+// last has no next page; with pages 0 there is always a next one. With
+// repeatFrom > 0, that page and every page after it name themselves as the
+// next page. Each request takes latency.
+type fakeSearch struct {
+	pages      int
+	perPage    int
+	repeatFrom int
+	latency    time.Duration
+
+	mu       sync.Mutex
+	requests int
+}
+
+func (f *fakeSearch) SearchResources(_ context.Context, request resourcesearch.SearchResourcesRequest) (resourcesearch.SearchResourcesResponse, error) {
+	f.mu.Lock()
+	f.requests++
+	f.mu.Unlock()
+	time.Sleep(f.latency)
+
+	page := 1
+	if request.Page != nil {
+		fmt.Sscan(*request.Page, &page)
+	}
+	var response resourcesearch.SearchResourcesResponse
+	for i := 0; i < f.perPage; i++ {
+		response.Items = append(response.Items, testResource(fmt.Sprintf("ocid1.instance.p%d.%d", page, i), compliantTags))
+	}
+	switch {
+	case f.repeatFrom > 0 && page >= f.repeatFrom:
+		response.OpcNextPage = common.String(fmt.Sprint(page))
+	case f.pages == 0 || page < f.pages:
+		response.OpcNextPage = common.String(fmt.Sprint(page + 1))
+	}
+	return response, nil
+}
+
+// testResource returns an instance with the given defined tags as JSON.
+func testResource(ocid, definedTags string) ResourceSummary {
+	r := ResourceSummary{
+		Identifier:    common.String(ocid),
+		DisplayName:   common.String(ocid),
+		ResourceType:  common.String("Instance"),
+		CompartmentId: common.String("ocid1.compartment.a"),
+	}
+	if err := json.Unmarshal([]byte(definedTags), &r.DefinedTags); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func TestPagerStopsOnRepeatedToken(t *testing.T) {
+	for _, prefetch := range []bool{false, true} {
+		cfg := testConfig(t)
+		client := &fakeSearch{perPage: 1, repeatFrom: 3}
+		pages := newPager(context.Background(), cfg, "DEFAULT", client, resourcesearch.SearchResourcesRequest{}, prefetch, nil)
+
+		n := 0
+		for {
+			_, more, err := pages.next()
+			if !more {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			n++
+		}
+		pages.close()
+		if !pages.stoppedRepeat {
+			t.Errorf("prefetch %v: stoppedRepeat not set", prefetch)
+		}
+		if n != 3 || client.requests != 3 {
+			t.Errorf("prefetch %v: %d pages from %d requests, want 3 of each", prefetch, n, client.requests)
+		}
+	}
+}
+
+func TestRepeatedTokenMarksRunTruncated(t *testing.T) {
+	cfg := testConfig(t, "-output-dir", t.TempDir(), "-rate", "0")
+	run := newAuditRun(cfg, func() {})
+	client := &fakeSearch{perPage: 1, repeatFrom: 3}
+
+	if _, err := ExecuteFullSearch(context.Background(), run, client, "us-ashburn-1", searchTarget{profile: "DEFAULT"}, "query all resources"); err != nil {
+		t.Fatal(err)
+	}
+	if err := run.finish(); err != nil {
+		t.Fatal(err)
+	}
+	if !run.manifest.Truncated || !strings.Contains(run.manifest.TruncatedReason, "page just requested") {
+		t.Errorf("truncated %v, reason %q", run.manifest.Truncated, run.manifest.TruncatedReason)
+	}
+}
+
+func TestPagerCloseWaitsForPrefetch(t *testing.T) {
+	cfg := testConfig(t, "-max-consecutive-empty", "1")
+	pages := newPager(context.Background(), cfg, "DEFAULT", &fakeSearch{perPage: 0}, resourcesearch.SearchResourcesRequest{}, true, nil)
+	if _, more, err := pages.next(); !more || err != nil {
+		t.Fatalf("first page: more %v, error %v", more, err)
+	}
+	// Stopping early must leave the counters safe to read, under -race.
+	pages.close()
+	pages.close()
+	_ = pages.emptyPages + pages.fetched
+	_ = pages.stoppedEmpty || pages.stoppedRepeat || pages.stoppedMaxPages
+}
+
+func TestPagerEmptyInteriorPages(t *testing.T) {
+	tests := []struct {
+		empty   int
+		stopped bool
+	}{
+		{1, false},
+		{2, false},
+		{3, true},
+	}
+	for _, tt := range tests {
+		for _, prefetch := range []bool{false, true} {
+			// A resource, tt.empty empty pages that each have a next
+			// page, and a last resource.
+			canned := [][]ResourceSummary{{testResource("ocid1.instance.first", compliantTags)}}
+			for i := 0; i < tt.empty; i++ {
+				canned = append(canned, nil)
+			}
+			canned = append(canned, []ResourceSummary{testResource("ocid1.instance.last", compliantTags)})
+
+			cfg := testConfig(t, "-max-consecutive-empty", "2", "-rate", "0")
+			pages := newPager(context.Background(), cfg, "DEFAULT", &cannedSearch{pages: canned}, resourcesearch.SearchResourcesRequest{}, prefetch, nil)
+			resources := 0
+			for {
+				response, more, err := pages.next()
+				if !more {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				resources += len(response.Items)
+			}
+			pages.close()
+
+			wantEmpty, wantResources := tt.empty, 2
+			if tt.stopped {
+				wantEmpty, wantResources = 3, 1
+			}
+			if pages.emptyPages != wantEmpty || pages.stoppedEmpty != tt.stopped || resources != wantResources {
+				t.Errorf("%d empty pages, prefetch %v: counted %d, stopped %v, %d resources, want %d, %v, %d",
+					tt.empty, prefetch, pages.emptyPages, pages.stoppedEmpty, resources, wantEmpty, tt.stopped, wantResources)
+			}
+		}
+	}
+}
+
+func TestMaxConsecutiveEmptyMarksRunTruncated(t *testing.T) {
+	client := &cannedSearch{pages: [][]ResourceSummary{{testResource("ocid1.instance.first", compliantTags)}, nil, nil, {testResource("ocid1.instance.last", compliantTags)}}}
+	cfg := testConfig(t, "-output-dir", t.TempDir(), "-max-consecutive-empty", "1", "-rate", "0")
+	run := newAuditRun(cfg, func() {})
+
+	tally, err := ExecuteFullSearch(context.Background(), run, client, "us-ashburn-1", searchTarget{profile: "DEFAULT"}, "query all resources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tally.counts.resources != 1 {
+		t.Errorf("%d resources, want the one before the empty pages", tally.counts.resources)
+	}
+	if err := run.finish(); err != nil {
+		t.Fatal(err)
+	}
+	if !run.manifest.Truncated || !strings.Contains(run.manifest.TruncatedReason, "-max-consecutive-empty") || run.manifest.Complete {
+		t.Errorf("truncated %v, reason %q, complete %v", run.manifest.Truncated, run.manifest.TruncatedReason, run.manifest.Complete)
+	}
+}
+
+func TestMaxPagesMarksRegionLimited(t *testing.T) {
+	for _, prefetch := range []string{"false", "true"} {
+		cfg := testConfig(t, "-output-dir", t.TempDir(), "-max-pages", "3", "-parallel-page-prefetch="+prefetch)
+		run := newAuditRun(cfg, func() {})
+		client := &fakeSearch{perPage: 2}
+
+		tally, err := ExecuteFullSearch(context.Background(), run, client, "us-ashburn-1", searchTarget{profile: "DEFAULT"}, "query all resources")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tally.counts.resources != 6 {
+			t.Errorf("prefetch %s: %d resources, want the 6 of 3 pages", prefetch, tally.counts.resources)
+		}
+		if !strings.Contains(tally.truncated, "-max-pages") {
+			t.Errorf("prefetch %s: region summary truncated %q", prefetch, tally.truncated)
+		}
+		if err := run.finish(); err != nil {
+			t.Fatal(err)
+		}
+		if !run.manifest.Truncated || !strings.Contains(run.manifest.TruncatedReason, "-max-pages") {
+			t.Errorf("prefetch %s: truncated %v, reason %q", prefetch, run.manifest.Truncated, run.manifest.TruncatedReason)
+		}
+	}
+}
+
+// scanPages runs one region scan over pages synthetic pages of 100
+// resources each.
+func scanPages(t testing.TB, cfg *config, pages int) {
+	t.Helper()
+	run := newAuditRun(cfg, func() {})
+	tally, err := ExecuteFullSearch(context.Background(), run, &fakeSearch{pages: pages, perPage: 100}, "us-ashburn-1", searchTarget{profile: "DEFAULT"}, "query all resources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tally.counts.resources != pages*100 {
+		t.Fatalf("%d resources, want %d", tally.counts.resources, pages*100)
+	}
+}
+
+func TestScanAllocationsPerPage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("scans thousands of resources")
+	}
+	cfg := testConfig(t, "-output-dir", t.TempDir(), "-missing-tags", "-no-owner", "-rate", "0")
+
+	// Rows are written and flushed page by page, so ten times the pages
+	// cost ten times the allocations, not more.
+	few := testing.AllocsPerRun(3, func() { scanPages(t, cfg, 5) })
+	many := testing.AllocsPerRun(3, func() { scanPages(t, cfg, 50) })
+	if perPage := many / 50; perPage > few/5*1.1 {
+		t.Errorf("%.0f allocations per page over 50 pages, %.0f over 5", perPage, few/5)
+	}
+}
+
+func TestScanHeapStaysFlat(t *testing.T) {
+	if testing.Short() {
+		t.Skip("scans thousands of resources")
+	}
+	cfg := testConfig(t, "-output-dir", t.TempDir(), "-missing-tags", "-no-owner", "-rate", "0")
+
+	// Sample the live heap after the 10th and the last of 200 pages.
+	var pages int
+	var early, late uint64
+	cfg.userHooks.OnPage = func(string, int) {
+		pages++
+		switch pages {
+		case 10:
+			early = liveHeap()
+		case 200:
+			late = liveHeap()
+		}
+	}
+	scanPages(t, cfg, 200)
+	if late > early+4<<20 {
+		t.Errorf("live heap grew from %d to %d bytes over 190 pages", early, late)
+	}
+}
+
+// liveHeap returns the bytes of live heap objects after a collection.
+func liveHeap() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// BenchmarkPaging pages through 20 pages whose requests take 2ms, spending
+// 2ms on each page, sequentially and with -parallel-page-prefetch. Prefetch
+// overlaps each request with the previous page's work, so a run takes about
+// half the time, with still one request in flight.
+func BenchmarkPaging(b *testing.B) {
+	for _, prefetch := range []bool{false, true} {
+		b.Run(fmt.Sprintf("prefetch=%v", prefetch), func(b *testing.B) {
+			cfg := testConfig(b, "-rate", "0")
+			for i := 0; i < b.N; i++ {
+				client := &fakeSearch{pages: 20, perPage: 100, latency: 2 * time.Millisecond}
+				pages := newPager(context.Background(), cfg, "DEFAULT", client, resourcesearch.SearchResourcesRequest{}, prefetch, nil)
+				for {
+					_, more, err := pages.next()
+					if !more {
+						break
+					}
+					if err != nil {
+						b.Fatal(err)
+					}
+					time.Sleep(2 * time.Millisecond)
+				}
+				pages.close()
+			}
+		})
+	}
+}
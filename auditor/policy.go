@@ -0,0 +1,240 @@
+package auditor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// tagRuleSpec is one entry of the -tag-rules file, keyed by "Namespace.Key":
+//
+//	{
+//	  "Operations.Environment": {
+//	    "allowed_values": ["prod", "dev", "test"],
+//	    "case_insensitive": true
+//	  },
+//	  "Operations.CostCenter": {
+//	    "pattern": "^CC-\\d{4}$"
+//	  }
+//	}
+type tagRuleSpec struct {
+	AllowedValues   []string `json:"allowed_values"`
+	Pattern         string   `json:"pattern"`
+	CaseInsensitive bool     `json:"case_insensitive"`
+}
+
+// tagRule is a validated tag rule.
+type tagRule struct {
+	namespace       string
+	key             string
+	allowedValues   []string
+	pattern         *regexp.Regexp
+	caseInsensitive bool
+}
+
+func (r tagRule) name() string {
+	return r.namespace + "." + r.key
+}
+
+// tagViolation describes a tag whose value breaks a rule.
+type tagViolation struct {
+	tag    string
+	value  string
+	reason string
+}
+
+// parseTagRef splits a "Namespace.Key" reference.
+func parseTagRef(ref string) (string, string, error) {
+	parts := strings.SplitN(strings.TrimSpace(ref), ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid tag %q, expected Namespace.Key", ref)
+	}
+	return parts[0], parts[1], nil
+}
+
+// definedTagValue looks up a defined tag, matching the namespace and key
+// case-insensitively like hasCreatedByTag does.
+func definedTagValue(definedTags map[string]map[string]interface{}, namespace, key string) (string, bool) {
+	for ns, tags := range definedTags {
+		if !strings.EqualFold(ns, namespace) {
+			continue
+		}
+		for k, v := range tags {
+			if strings.EqualFold(k, key) {
+				return fmt.Sprint(v), true
+			}
+		}
+	}
+	return "", false
+}
+
+// loadTagRules reads and validates the -tag-rules file. Rules are returned
+// sorted by tag name so reports are deterministic.
+func loadTagRules(path string) ([]tagRule, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading tag rules: %w", err)
+	}
+
+	var specs map[string]tagRuleSpec
+	if err := json.Unmarshal(bytes, &specs); err != nil {
+		return nil, fmt.Errorf("error parsing tag rules %s: %w", path, err)
+	}
+
+	var rules []tagRule
+	for ref, spec := range specs {
+		namespace, key, err := parseTagRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		if len(spec.AllowedValues) == 0 && spec.Pattern == "" {
+			return nil, fmt.Errorf("tag rule %s: needs allowed_values or a pattern", ref)
+		}
+		if spec.AllowedValues != nil && len(spec.AllowedValues) == 0 {
+			return nil, fmt.Errorf("tag rule %s: allowed_values must not be empty", ref)
+		}
+
+		var pattern *regexp.Regexp
+		if spec.Pattern != "" {
+			expr := spec.Pattern
+			if spec.CaseInsensitive {
+				expr = "(?i)" + expr
+			}
+			if pattern, err = regexp.Compile(expr); err != nil {
+				return nil, fmt.Errorf("tag rule %s: invalid pattern: %w", ref, err)
+			}
+		}
+
+		seen := make(map[string]bool)
+		for _, v := range spec.AllowedValues {
+			if strings.TrimSpace(v) == "" {
+				return nil, fmt.Errorf("tag rule %s: allowed_values contains an empty value", ref)
+			}
+			if spec.CaseInsensitive {
+				v = strings.ToLower(v)
+			}
+			if seen[v] {
+				return nil, fmt.Errorf("tag rule %s: duplicate allowed value %q", ref, v)
+			}
+			seen[v] = true
+		}
+
+		rules = append(rules, tagRule{
+			namespace:       namespace,
+			key:             key,
+			allowedValues:   spec.AllowedValues,
+			pattern:         pattern,
+			caseInsensitive: spec.CaseInsensitive,
+		})
+	}
+
+	sort.Slice(rules, func(i, j int) bool { return rules[i].name() < rules[j].name() })
+	return rules, nil
+}
+
+func (r tagRule) allows(value string) bool {
+	if len(r.allowedValues) == 0 {
+		return true
+	}
+	for _, allowed := range r.allowedValues {
+		if value == allowed || (r.caseInsensitive && strings.EqualFold(value, allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTagRules returns the rule violations of a resource. Tags that are
+// absent are not violations; presence is covered by the other reports.
+func checkTagRules(definedTags map[string]map[string]interface{}, rules []tagRule) []tagViolation {
+	var violations []tagViolation
+	for _, rule := range rules {
+		value, ok := definedTagValue(definedTags, rule.namespace, rule.key)
+		if !ok {
+			continue
+		}
+		var reason string
+		switch {
+		case !rule.allows(value):
+			reason = fmt.Sprintf("not one of: %s", strings.Join(rule.allowedValues, ", "))
+		case rule.pattern != nil && !rule.pattern.MatchString(value):
+			reason = fmt.Sprintf("not matched by pattern %s", rule.pattern)
+		default:
+			continue
+		}
+		violations = append(violations, tagViolation{tag: rule.name(), value: value, reason: reason})
+	}
+	return violations
+}
+
+// requiredTagPolicy is the -required-tags-policy JSON file, mapping
+// resource types to the defined tags they must carry:
+//
+//	{
+//	  "default": ["Operations.CreatedBy"],
+//	  "types": {
+//	    "Instance": ["Operations.CreatedBy", "Finance.CostCenter"],
+//	    "Bucket": ["Operations.CreatedBy", "Security.DataClassification"]
+//	  }
+//	}
+//
+// A type's list replaces the default rather than adding to it. Without a
+// default, types not listed use -required-tags.
+type requiredTagPolicy struct {
+	Default []string            `json:"default"`
+	Types   map[string][]string `json:"types"`
+}
+
+// loadRequiredTagPolicy reads and validates the -required-tags-policy file.
+// The returned map is keyed by lowercased resource type; fallback is the
+// default list, or nil when the file has none.
+func loadRequiredTagPolicy(path string) (map[string][]tagRef, []tagRef, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading required tags policy: %w", err)
+	}
+
+	var policy requiredTagPolicy
+	if err := json.Unmarshal(bytes, &policy); err != nil {
+		// Like the other input files, the policy is JSON only.
+		if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+			return nil, nil, fmt.Errorf("error parsing required tags policy %s: YAML is not supported, write the policy as JSON: %w", path, err)
+		}
+		return nil, nil, fmt.Errorf("error parsing required tags policy %s: %w", path, err)
+	}
+
+	refs := func(list []string) ([]tagRef, error) {
+		var out []tagRef
+		for _, item := range list {
+			namespace, key, err := parseTagRef(item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, tagRef{namespace: namespace, key: key})
+		}
+		return out, nil
+	}
+
+	fallback, err := refs(policy.Default)
+	if err != nil {
+		return nil, nil, fmt.Errorf("required tags policy default: %w", err)
+	}
+	byType := make(map[string][]tagRef)
+	for resourceType, list := range policy.Types {
+		key := strings.ToLower(strings.TrimSpace(resourceType))
+		if key == "" {
+			return nil, nil, fmt.Errorf("required tags policy: empty resource type")
+		}
+		if _, ok := byType[key]; ok {
+			return nil, nil, fmt.Errorf("required tags policy: resource type %s listed twice", resourceType)
+		}
+		if byType[key], err = refs(list); err != nil {
+			return nil, nil, fmt.Errorf("required tags policy type %s: %w", resourceType, err)
+		}
+	}
+	return byType, fallback, nil
+}
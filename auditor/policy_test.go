@@ -0,0 +1,101 @@
+package auditor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestFile writes content to a file in a temporary directory and
+// returns its path.
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheckTagRules(t *testing.T) {
+	rules, err := loadTagRules(writeTestFile(t, "rules.json", `{
+		"Ops.Environment": {"allowed_values": ["prod", "dev", "test"]},
+		"Ops.Tier": {"allowed_values": ["gold", "silver"], "case_insensitive": true},
+		"Ops.CostCenter": {"pattern": "^CC-\\d{4}$"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		definedTags map[string]map[string]interface{}
+		want        string
+	}{
+		{"allowed value", map[string]map[string]interface{}{"Ops": {"Environment": "prod"}}, ""},
+		{"value outside the set", map[string]map[string]interface{}{"Ops": {"Environment": "staging"}}, "Ops.Environment=staging is not one of: prod, dev, test"},
+		{"case sensitive by default", map[string]map[string]interface{}{"Ops": {"Environment": "Prod"}}, "Ops.Environment=Prod is not one of: prod, dev, test"},
+		{"case insensitive rule", map[string]map[string]interface{}{"Ops": {"Tier": "GOLD"}}, ""},
+		{"case insensitive rule, value outside the set", map[string]map[string]interface{}{"Ops": {"Tier": "bronze"}}, "Ops.Tier=bronze is not one of: gold, silver"},
+		{"namespace and key match in any case", map[string]map[string]interface{}{"ops": {"environment": "qa"}}, "Ops.Environment=qa is not one of: prod, dev, test"},
+		{"pattern", map[string]map[string]interface{}{"Ops": {"CostCenter": "CC-12"}}, `Ops.CostCenter=CC-12 is not matched by pattern ^CC-\d{4}$`},
+		{"absent tag", map[string]map[string]interface{}{"Other": {"Environment": "staging"}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range checkTagRules(tt.definedTags, rules) {
+				got = append(got, v.tag+"="+v.value+" is "+v.reason)
+			}
+			if strings.Join(got, "; ") != tt.want {
+				t.Errorf("violations = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadTagRulesValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		want  string
+	}{
+		{"empty list", `{"Ops.Env": {"allowed_values": [], "pattern": "^p"}}`, "allowed_values must not be empty"},
+		{"no check", `{"Ops.Env": {}}`, "needs allowed_values or a pattern"},
+		{"blank value", `{"Ops.Env": {"allowed_values": ["prod", " "]}}`, "contains an empty value"},
+		{"duplicate", `{"Ops.Env": {"allowed_values": ["prod", "prod"]}}`, "duplicate allowed value"},
+		{"duplicate ignoring case", `{"Ops.Env": {"allowed_values": ["prod", "PROD"], "case_insensitive": true}}`, "duplicate allowed value"},
+		{"bad tag name", `{"Env": {"allowed_values": ["prod"]}}`, "expected Namespace.Key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadTagRules(writeTestFile(t, "rules.json", tt.rules))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := loadTagRules(writeTestFile(t, "rules.json", `{"Ops.Env": {"allowed_values": ["prod", "PROD"]}}`)); err != nil {
+		t.Errorf("values differing in case are distinct without case_insensitive: %v", err)
+	}
+}
+
+func TestLoadRequiredTagPolicy(t *testing.T) {
+	byType, fallback, err := loadRequiredTagPolicy(writeTestFile(t, "policy.json", `{
+		"default": ["Ops.CreatedBy"],
+		"types": {"Instance": ["Ops.CreatedBy", "Finance.CostCenter"]}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fallback) != 1 || len(byType["instance"]) != 2 {
+		t.Errorf("default %v, types %v", fallback, byType)
+	}
+
+	_, _, err = loadRequiredTagPolicy(writeTestFile(t, "policy.yaml", "default:\n  - Ops.CreatedBy\n"))
+	if err == nil || !strings.Contains(err.Error(), "YAML is not supported") {
+		t.Errorf("YAML policy: error = %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package auditor
+
+import (
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// postHookArgs splits a -post-hook template into arguments and substitutes
+// {file} in each. The command is run directly, not through a shell, so file
+// names need no quoting.
+func postHookArgs(template, path string) []string {
+	args := strings.Fields(template)
+	for i, arg := range args {
+		args[i] = strings.ReplaceAll(arg, "{file}", path)
+	}
+	return args
+}
+
+// runPostHooks runs the -post-hook command once for every output file
+// recorded so far, at most concurrency at a time. The files must be closed.
+// Failures are logged and recorded in the manifest; the files are kept.
+func (run *auditRun) runPostHooks(template string, concurrency int) {
+	run.manifest.mu.Lock()
+	files := append([]string{}, run.manifest.Files...)
+	run.manifest.mu.Unlock()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failures = make(map[string]string)
+		sem      = make(chan struct{}, concurrency)
+	)
+	for _, path := range files {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			args := postHookArgs(template, path)
+			output, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+			if err != nil {
+				slog.Warn("Post hook failed", "path", path, "error", err, "output", strings.TrimSpace(string(output)))
+				mu.Lock()
+				failures[path] = err.Error()
+				mu.Unlock()
+			}
+		}(path)
+	}
+	wg.Wait()
+
+	slog.Info("Post hook finished", "files", len(files), "failed", len(failures))
+	if len(failures) > 0 {
+		run.manifest.mu.Lock()
+		run.manifest.PostHookFailures = failures
+		run.manifest.mu.Unlock()
+	}
+}
+
+// validatePostHook checks a -post-hook template before the audit starts.
+func validatePostHook(template string) error {
+	args := strings.Fields(template)
+	if len(args) == 0 {
+		return fmt.Errorf("empty command")
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package auditor
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// progressTracker logs the running count of resources of every region at a
+// fixed interval for -progress, so a slow region can be told apart from a
+// stuck one. It is fed by an OnPage hook and is safe for concurrent use.
+type progressTracker struct {
+	mu      sync.Mutex
+	started time.Time
+	regions map[string]*regionProgress
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type regionProgress struct {
+	resources int
+	pages     int
+	lastPage  time.Time
+	finished  bool
+}
+
+func newProgressTracker() *progressTracker {
+	return &progressTracker{started: time.Now(), regions: make(map[string]*regionProgress)}
+}
+
+func (p *progressTracker) region(name string) *regionProgress {
+	r, ok := p.regions[name]
+	if !ok {
+		r = &regionProgress{lastPage: time.Now()}
+		p.regions[name] = r
+	}
+	return r
+}
+
+func (p *progressTracker) onPage(region string, pageItems int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r := p.region(region)
+	r.resources += pageItems
+	r.pages++
+	r.lastPage = time.Now()
+}
+
+// begin lists a region from the start of its scan, before its first page.
+func (p *progressTracker) begin(region string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.region(region)
+}
+
+// finish marks a region's scan as ended, successful or not.
+func (p *progressTracker) finish(region string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.region(region).finished = true
+}
+
+// start logs the progress every interval until stopped.
+func (p *progressTracker) start(interval time.Duration) {
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.log()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the progress logging.
+func (p *progressTracker) Stop() {
+	close(p.stop)
+	<-p.done
+}
+
+// log writes one line per region still scanning, with the time since its
+// last page, and a line for the whole run.
+func (p *progressTracker) log() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	names := make([]string, 0, len(p.regions))
+	for name := range p.regions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var total, finished int
+	for _, name := range names {
+		r := p.regions[name]
+		total += r.resources
+		if r.finished {
+			finished++
+			continue
+		}
+		slog.Info("Progress", "region", name, "resources", r.resources, "pages", r.pages,
+			"since_last_page", time.Since(r.lastPage).Round(time.Second))
+	}
+	slog.Info("Progress", "resources", total, "regions_finished", finished, "regions_started", len(names),
+		"elapsed", time.Since(p.started).Round(time.Second))
+}
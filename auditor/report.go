@@ -0,0 +1,295 @@
+package auditor
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Output formats of the -format flag.
+const (
+	formatCSV   = "csv"
+	formatJSON  = "json"
+	formatJSONL = "jsonl"
+)
+
+// reportFile is an open report file. CSV reports are written through
+// writer; JSON and JSONL reports through out, one object per row keyed by
+// the headers.
+type reportFile struct {
+	file   *outputFile
+	writer *csv.Writer
+
+	format  string
+	headers []string
+	out     *bufio.Writer
+	rows    int
+
+	// sheet receives the rows of -format xlsx reports.
+	sheet *xlsxSheet
+
+	// With -sort-by, rows are held in pending and written on Close, ordered
+	// by the sortColumn field and then by Identifier.
+	sorted     bool
+	sortColumn int
+	idColumn   int
+	pending    [][]string
+}
+
+// sortKeys map the -sort-by values to the column they order reports by.
+var sortKeys = map[string]string{
+	"identifier":   "Identifier",
+	"displayname":  "Display Name",
+	"timecreated":  "Time Created (UTC)",
+	"resourcetype": "Resource Type",
+	"compartment":  "Compartment ID",
+}
+
+// openReport creates a report file, records it in the manifest and writes
+// its header row.
+func (run *auditRun) openReport(path string, headers []string) (*reportFile, error) {
+	file, err := run.createReport(path)
+	if err != nil {
+		return nil, err
+	}
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write(headers); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error writing header: %w", err)
+	}
+	return &reportFile{file: file, writer: writer, format: formatCSV}, nil
+}
+
+// sheetNames name the workbook sheets of the -format xlsx reports after
+// the section.
+var sheetNames = map[string]string{
+	"resources":    "%s",
+	"missing_tags": "%s missing tags",
+	"no_owner":     "%s no owner",
+}
+
+// openFormattedReport creates a per-section report in the -format output
+// format, with the matching file extension. With -format xlsx it is a sheet
+// of the run's workbook instead of a file. With -sort-by its rows are
+// buffered until Close.
+func (run *auditRun) openFormattedReport(section, kind string, headers []string) (*reportFile, error) {
+	f, err := run.createFormattedReport(section, kind, headers)
+	if err != nil {
+		return nil, err
+	}
+	f.holdSorted(headers, run.cfg.sortBy)
+	return f, nil
+}
+
+// holdSorted makes the report buffer its rows sorted by key, a -sort-by
+// value; it does nothing for an empty key.
+func (f *reportFile) holdSorted(headers []string, key string) {
+	if key == "" {
+		return
+	}
+	f.sorted = true
+	f.sortColumn = indexOf(headers, sortKeys[key])
+	f.idColumn = indexOf(headers, "Identifier")
+}
+
+// indexOf returns the position of value in list, or -1.
+func indexOf(list []string, value string) int {
+	for i, v := range list {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
+
+func (run *auditRun) createFormattedReport(section, kind string, headers []string) (*reportFile, error) {
+	switch run.cfg.outputFormat {
+	case formatCSV:
+		return run.openReport(run.reportPath(section, kind), headers)
+	case formatXLSX:
+		sheet, err := run.workbook.addSheet(fmt.Sprintf(sheetNames[kind], section), headers)
+		if err != nil {
+			return nil, err
+		}
+		return &reportFile{format: formatXLSX, sheet: sheet}, nil
+	}
+
+	path := run.outputPath(fmt.Sprintf("%s_%s_%s.%s", section, kind, run.timestamp, run.cfg.outputFormat))
+	file, err := run.createReport(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &reportFile{file: file, format: run.cfg.outputFormat, headers: headers, out: bufio.NewWriter(file)}
+	if f.format == formatJSON {
+		f.out.WriteString("[")
+	}
+	return f, nil
+}
+
+// appendReport reopens a report created earlier in the run to add rows.
+// With -gzip the rows are added as a further gzip member, which readers
+// decompress as part of the same stream.
+func (run *auditRun) appendReport(path string) (*reportFile, error) {
+	compress := run.cfg.gzipOutput && !run.cfg.dryRun
+	switch {
+	case run.cfg.dryRun:
+		path = os.DevNull
+	case run.cfg.gzipOutput:
+		path += ".gz"
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error reopening report: %w", err)
+	}
+	out := newOutputFile(file, compress)
+	return &reportFile{file: out, writer: csv.NewWriter(out), format: formatCSV}, nil
+}
+
+func (f *reportFile) Write(row []string) error {
+	if f.sorted {
+		f.pending = append(f.pending, row)
+		return nil
+	}
+	if f.writer != nil {
+		return f.writer.Write(row)
+	}
+	if f.sheet != nil {
+		return f.sheet.writeRow(row, false)
+	}
+
+	object, err := f.object(row)
+	if err != nil {
+		return err
+	}
+	switch {
+	case f.format == formatJSONL:
+	case f.rows > 0:
+		f.out.WriteString(",\n  ")
+	default:
+		f.out.WriteString("\n  ")
+	}
+	f.rows++
+	if _, err := f.out.Write(object); err != nil {
+		return err
+	}
+	if f.format == formatJSONL {
+		_, err = f.out.WriteString("\n")
+	}
+	return err
+}
+
+// object encodes a row as a JSON object with its fields in header order.
+// Defined tags are kept as a nested object; columns a row does not have
+// are left out.
+func (f *reportFile) object(row []string) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("{")
+	for i, header := range f.headers {
+		if i >= len(row) {
+			break
+		}
+		key, err := json.Marshal(header)
+		if err != nil {
+			return nil, err
+		}
+		var value []byte
+		if header == "Defined Tags" && row[i] != "" && json.Valid([]byte(row[i])) {
+			value = []byte(row[i])
+		} else if value, err = json.Marshal(row[i]); err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.Write(key)
+		b.WriteString(":")
+		b.Write(value)
+	}
+	b.WriteString("}")
+	return []byte(b.String()), nil
+}
+
+// Flush writes any buffered rows to the file.
+func (f *reportFile) Flush() error {
+	if f.writer != nil {
+		f.writer.Flush()
+		if err := f.writer.Error(); err != nil {
+			return err
+		}
+		return f.file.Flush()
+	}
+	if f.sheet != nil {
+		return f.sheet.Flush()
+	}
+	if err := f.out.Flush(); err != nil {
+		return err
+	}
+	return f.file.Flush()
+}
+
+// writeSorted writes the rows held for -sort-by in order. Rows that tie on
+// the sort column are ordered by Identifier, then by their other columns,
+// so a resource returned by several regions is listed the same way every
+// run.
+func (f *reportFile) writeSorted() error {
+	key, id := f.sortColumn, f.idColumn
+	rows := f.pending
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a[key] != b[key] {
+			return a[key] < b[key]
+		}
+		if a[id] != b[id] {
+			return a[id] < b[id]
+		}
+		return slices.Compare(a, b) < 0
+	})
+	f.sorted, f.pending = false, nil
+	for _, row := range rows {
+		if err := f.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close writes any rows held for -sort-by, flushes any buffered rows and
+// closes the file.
+func (f *reportFile) Close() error {
+	if f.sorted {
+		if err := f.writeSorted(); err != nil {
+			f.close()
+			return err
+		}
+	}
+	return f.close()
+}
+
+func (f *reportFile) close() error {
+	if f.writer != nil {
+		f.writer.Flush()
+		if err := f.writer.Error(); err != nil {
+			f.file.Close()
+			return err
+		}
+		return f.file.Close()
+	}
+	if f.sheet != nil {
+		return f.sheet.Close()
+	}
+
+	if f.format == formatJSON {
+		f.out.WriteString("\n]\n")
+	}
+	if err := f.out.Flush(); err != nil {
+		f.file.Close()
+		return err
+	}
+	return f.file.Close()
+}
@@ -0,0 +1,101 @@
+package auditor
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/oracle/oci-go-sdk/v65/identity"
+)
+
+// namespaceStatus caches which tag namespaces of the tenancy are retired.
+// It is filled once with a single paginated ListTagNamespaces call, so
+// resources are checked without further API calls.
+type namespaceStatus struct {
+	retired map[string]bool
+}
+
+func loadNamespaceStatus(ctx context.Context, client identity.IdentityClient, tenancyID string) (*namespaceStatus, error) {
+	namespaces, err := listTagNamespaces(ctx, client, tenancyID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &namespaceStatus{retired: make(map[string]bool)}
+	for _, ns := range namespaces {
+		if ns.IsRetired != nil && *ns.IsRetired {
+			status.retired[strings.ToLower(getStringValue(ns.Name))] = true
+		}
+	}
+	return status, nil
+}
+
+// retiredNamespaces returns the sorted retired namespaces used by a
+// resource's defined tags.
+func (s *namespaceStatus) retiredNamespaces(definedTags map[string]map[string]interface{}) []string {
+	var retired []string
+	for namespace := range definedTags {
+		if s.retired[strings.ToLower(namespace)] {
+			retired = append(retired, namespace)
+		}
+	}
+	sort.Strings(retired)
+	return retired
+}
+
+// retiredUsage counts resources per retired namespace across all regions.
+// It is fed by an OnResource hook and is safe for concurrent use.
+type retiredUsage struct {
+	status *namespaceStatus
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newRetiredUsage(status *namespaceStatus) *retiredUsage {
+	return &retiredUsage{status: status, counts: make(map[string]int)}
+}
+
+func (u *retiredUsage) onResource(_ string, r ResourceSummary) {
+	retired := u.status.retiredNamespaces(r.DefinedTags)
+	if len(retired) == 0 {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, namespace := range retired {
+		u.counts[namespace]++
+	}
+}
+
+// Write writes the number of resources still using each retired namespace,
+// most used first, and logs the same counts.
+func (u *retiredUsage) Write(run *auditRun) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	file, err := run.createReport(run.outputPath(fmt.Sprintf("retired_namespace_usage_%s.csv", run.timestamp)))
+	if err != nil {
+		return fmt.Errorf("error creating retired namespace report: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	if err := writer.Write([]string{"Retired Namespace", "Resources"}); err != nil {
+		return fmt.Errorf("error writing retired namespace header: %w", err)
+	}
+	for _, namespace := range byCountDesc(u.counts) {
+		slog.Info("Retired namespace still in use", "namespace", namespace, "resources", u.counts[namespace])
+		if err := writer.Write([]string{namespace, fmt.Sprintf("%d", u.counts[namespace])}); err != nil {
+			return fmt.Errorf("error writing retired namespace report: %w", err)
+		}
+	}
+	return nil
+}
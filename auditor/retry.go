@@ -0,0 +1,47 @@
+package auditor
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+)
+
+// maxBackoff caps the pause between two attempts of a page request.
+const maxBackoff = 30 * time.Second
+
+// isRetryable reports whether a failed search request may succeed when
+// sent again: throttling (HTTP 429), server errors (5xx) and network errors
+// not caused by the run's own cancellation. Other service errors, such as
+// 401, 403 or 404, are permanent.
+func isRetryable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	if serviceErr, ok := common.IsServiceError(err); ok {
+		code := serviceErr.GetHTTPStatusCode()
+		return code == 429 || code >= 500
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// backoff returns the pause before retry attempt n, counted from 1: the
+// base doubled per attempt, capped at maxBackoff, with up to 50% jitter
+// either way so that regions throttled together do not retry together.
+func backoff(base time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay)))
+}
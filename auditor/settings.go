@@ -0,0 +1,121 @@
+package auditor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// defaultQuery is the structured search query used when none is configured.
+const defaultQuery = "query all resources"
+
+// Settings is the optional -settings file:
+//
+//	{
+//	  "query": "query all resources",
+//	  "region_queries": {
+//	    "us-ashburn-1": "query instance, vcn resources"
+//	  }
+//	}
+type Settings struct {
+	// Query replaces the default query for every region.
+	Query string `json:"query"`
+	// RegionQueries overrides Query for individual config sections.
+	RegionQueries map[string]string `json:"region_queries"`
+}
+
+// queryPattern is a loose check of the structured search grammar,
+// "query <types> resources [where ...]", to catch typos before any API call.
+var queryPattern = regexp.MustCompile(`(?is)^query\s+\S.*?\s+resources(\s+.*)?$`)
+
+func validateQuery(query string) error {
+	if strings.TrimSpace(query) == "" {
+		return fmt.Errorf("query is empty")
+	}
+	if !queryPattern.MatchString(strings.TrimSpace(query)) {
+		return fmt.Errorf("query %q is not of the form \"query <types> resources [where ...]\"", query)
+	}
+	return nil
+}
+
+// knownResourceTypes are common resource types of the search service, lower
+// cased. The service supports more, so -resource-types only warns about
+// types missing here.
+var knownResourceTypes = map[string]bool{
+	"analyticsinstance": true, "apigateway": true, "autonomousdatabase": true,
+	"bastion": true, "bootvolume": true, "bootvolumebackup": true, "bucket": true,
+	"cluster": true, "compartment": true, "containerinstance": true,
+	"customerpremisesequipment": true, "database": true, "dbsystem": true,
+	"dhcpoptions": true, "drg": true, "dynamicgroup": true, "filesystem": true,
+	"functionsapplication": true, "functionsfunction": true, "group": true,
+	"image": true, "instance": true, "instancepool": true, "internetgateway": true,
+	"key": true, "loadbalancer": true, "localpeeringgateway": true,
+	"mounttarget": true, "natgateway": true, "networkloadbalancer": true,
+	"networksecuritygroup": true, "ocirrepository": true, "policy": true,
+	"privateip": true, "publicip": true, "routetable": true, "securitylist": true,
+	"servicegateway": true, "stream": true, "subnet": true, "tagdefault": true,
+	"tagnamespace": true, "user": true, "vault": true, "vcn": true, "vnic": true,
+	"volume": true, "volumebackup": true, "volumegroup": true,
+}
+
+var resourceTypePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+
+// resourceTypesQuery builds "query <types> resources" from a -resource-types
+// list. Malformed names are errors; names missing from knownResourceTypes
+// are returned for the caller to warn about.
+func resourceTypesQuery(list string) (string, []string, error) {
+	types := splitList(list)
+	if len(types) == 0 {
+		return "", nil, fmt.Errorf("no resource types given")
+	}
+	var unknown []string
+	for _, t := range types {
+		if !resourceTypePattern.MatchString(t) {
+			return "", nil, fmt.Errorf("invalid resource type %q", t)
+		}
+		if !knownResourceTypes[strings.ToLower(t)] {
+			unknown = append(unknown, t)
+		}
+	}
+	return fmt.Sprintf("query %s resources", strings.Join(types, ", ")), unknown, nil
+}
+
+func loadSettings(path string) (*Settings, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading settings: %w", err)
+	}
+
+	var settings Settings
+	if err := json.Unmarshal(bytes, &settings); err != nil {
+		return nil, fmt.Errorf("error parsing settings %s: %w", path, err)
+	}
+
+	if settings.Query != "" {
+		if err := validateQuery(settings.Query); err != nil {
+			return nil, fmt.Errorf("settings query: %w", err)
+		}
+	}
+	for region, query := range settings.RegionQueries {
+		if err := validateQuery(query); err != nil {
+			return nil, fmt.Errorf("settings region_queries[%s]: %w", region, err)
+		}
+	}
+	return &settings, nil
+}
+
+// queryFor returns the query to run for a config section: its region
+// override if any, else the global query, else defaultQuery.
+func (s *Settings) queryFor(section string) string {
+	if s != nil {
+		if query, ok := s.RegionQueries[section]; ok {
+			return strings.TrimSpace(query)
+		}
+		if s.Query != "" {
+			return strings.TrimSpace(s.Query)
+		}
+	}
+	return defaultQuery
+}
@@ -0,0 +1,45 @@
+package auditor
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+// checkSerializable reports the first field of a resource that cannot be
+// serialized faithfully. DefinedTagsToString returns "" for tags that do
+// not marshal, and invalid UTF-8 is silently replaced when encoding, so
+// with -strict-json such resources fail instead.
+func checkSerializable(r ResourceSummary) error {
+	if _, err := json.Marshal(r.DefinedTags); err != nil {
+		return fmt.Errorf("defined tags: %w", err)
+	}
+	for namespace, tags := range r.DefinedTags {
+		for key, value := range tags {
+			s, isString := value.(string)
+			if !utf8.ValidString(namespace) || !utf8.ValidString(key) || (isString && !utf8.ValidString(s)) {
+				return fmt.Errorf("defined tag %s.%s is not valid UTF-8", namespace, key)
+			}
+		}
+	}
+	for key, value := range r.FreeformTags {
+		if !utf8.ValidString(key) || !utf8.ValidString(value) {
+			return fmt.Errorf("freeform tag %q is not valid UTF-8", key)
+		}
+	}
+	if !utf8.ValidString(getStringValue(r.DisplayName)) {
+		return fmt.Errorf("display name is not valid UTF-8")
+	}
+	return nil
+}
+
+// addStrictFailures records a region's -strict-json failures in the
+// manifest.
+func (m *Manifest) addStrictFailures(section string, count int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.StrictJSONFailures == nil {
+		m.StrictJSONFailures = make(map[string]int)
+	}
+	m.StrictJSONFailures[section] = count
+}
@@ -0,0 +1,194 @@
+package auditor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// regionTally is the outcome of one region's scan, as returned by
+// ExecuteFullSearch for the run summary.
+type regionTally struct {
+	region string
+	counts tallyCounts
+	byType map[string]*tallyCounts
+	// byCompartment counts the checked resources of each compartment, by
+	// OCID, for -compartment-compliance.
+	byCompartment map[string]*compartmentTally
+	// truncated is why the region's scan ended early, or "".
+	truncated string
+}
+
+// tallyCounts counts resources, and those missing tags or an owner. In-flight
+// resources and resources in their grace period are counted as resources
+// only, since they are not checked.
+type tallyCounts struct {
+	resources   int
+	missingTags int
+	noOwner     int
+}
+
+func newRegionTally(region string) *regionTally {
+	return &regionTally{region: region, byType: make(map[string]*tallyCounts), byCompartment: make(map[string]*compartmentTally)}
+}
+
+// addCompliance counts a checked resource of a compartment; compliant tells
+// whether it carries all its required tags.
+func (t *regionTally) addCompliance(compartmentID string, compliant bool) {
+	c, ok := t.byCompartment[compartmentID]
+	if !ok {
+		c = &compartmentTally{}
+		t.byCompartment[compartmentID] = c
+	}
+	c.resources++
+	if compliant {
+		c.compliant++
+	}
+}
+
+// add counts a resource; missing and noOwner are its check results.
+func (t *regionTally) add(resourceType string, missing, noOwner bool) {
+	c, ok := t.byType[resourceType]
+	if !ok {
+		c = &tallyCounts{}
+		t.byType[resourceType] = c
+	}
+	for _, c := range []*tallyCounts{&t.counts, c} {
+		c.resources++
+		if missing {
+			c.missingTags++
+		}
+		if noOwner {
+			c.noOwner++
+		}
+	}
+}
+
+// row returns the summary line of the counts; truncated is why they are
+// partial, or "".
+func (c tallyCounts) row(region, resourceType, truncated string) []string {
+	return []string{region, resourceType, fmt.Sprintf("%d", c.resources), fmt.Sprintf("%d", c.missingTags), fmt.Sprintf("%d", c.noOwner),
+		strconv.FormatBool(truncated != ""), truncated}
+}
+
+// runSummary collects the tallies of every region. It is safe for
+// concurrent use.
+type runSummary struct {
+	mu      sync.Mutex
+	regions map[string]*regionTally
+}
+
+func newRunSummary() *runSummary {
+	return &runSummary{regions: make(map[string]*regionTally)}
+}
+
+func (s *runSummary) add(t *regionTally) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.regions[t.region] = t
+}
+
+// regionCounts returns the counts of every region, in name order.
+func (s *runSummary) regionCounts() []RegionCounts {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	regions := make([]string, 0, len(s.regions))
+	for region := range s.regions {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	counts := make([]RegionCounts, len(regions))
+	for i, region := range regions {
+		c := s.regions[region].counts
+		counts[i] = RegionCounts{Region: region, Resources: c.resources, MissingTags: c.missingTags, NoOwner: c.noOwner}
+	}
+	return counts
+}
+
+// Write writes summary_<timestamp>.csv: per region a line for all its
+// resources followed by one line per resource type, largest first, and a
+// grand total at the bottom. Lines of partial counts are marked truncated,
+// with the reason.
+func (s *runSummary) Write(run *auditRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := run.createReport(run.outputPath(fmt.Sprintf("summary_%s.csv", run.timestamp)))
+	if err != nil {
+		return fmt.Errorf("error creating summary: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	headers := []string{"Region", "Resource Type", "Resources", "Missing Tags", "No Owner", "Truncated", "Truncated Reason"}
+	if err := writer.Write(headers); err != nil {
+		return fmt.Errorf("error writing summary header: %w", err)
+	}
+	// With -format xlsx the summary is also the workbook's first sheet.
+	var sheet *xlsxSheet
+	if run.workbook != nil {
+		if sheet, err = run.workbook.addSummarySheet(headers); err != nil {
+			return err
+		}
+		defer sheet.Close()
+	}
+	write := func(rows ...[]string) error {
+		if err := writer.WriteAll(rows); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if sheet == nil {
+				break
+			}
+			if err := sheet.writeRow(row, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	regions := make([]string, 0, len(s.regions))
+	for region := range s.regions {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	var total tallyCounts
+	for _, region := range regions {
+		t := s.regions[region]
+		total.resources += t.counts.resources
+		total.missingTags += t.counts.missingTags
+		total.noOwner += t.counts.noOwner
+
+		types := make([]string, 0, len(t.byType))
+		for resourceType := range t.byType {
+			types = append(types, resourceType)
+		}
+		sort.Slice(types, func(i, j int) bool {
+			a, b := t.byType[types[i]].resources, t.byType[types[j]].resources
+			if a != b {
+				return a > b
+			}
+			return types[i] < types[j]
+		})
+
+		rows := [][]string{t.counts.row(region, "(all)", t.truncated)}
+		for _, resourceType := range types {
+			rows = append(rows, t.byType[resourceType].row(region, resourceType, t.truncated))
+		}
+		if err := write(rows...); err != nil {
+			return fmt.Errorf("error writing summary: %w", err)
+		}
+	}
+
+	if err := write(total.row("(all)", "(all)", run.truncatedReason())); err != nil {
+		return fmt.Errorf("error writing summary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package auditor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSummaryMarksTruncatedRegions(t *testing.T) {
+	dir := t.TempDir()
+	run := newAuditRun(testConfig(t, "-output-dir", dir, "-max-pages", "3"), func() {})
+	summary := newRunSummary()
+
+	complete := newRegionTally("FRA")
+	complete.add("Instance", false, false)
+	summary.add(complete)
+	partial := newRegionTally("PHX")
+	partial.add("Instance", true, false)
+	partial.truncated = run.cfg.truncation("max-pages")
+	summary.add(partial)
+	run.markPageLimited()
+
+	if err := summary.Write(run); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Region", "Resource Type", "Resources", "Missing Tags", "No Owner", "Truncated", "Truncated Reason"},
+		{"FRA", "(all)", "1", "0", "0", "false", ""},
+		{"FRA", "Instance", "1", "0", "0", "false", ""},
+		{"PHX", "(all)", "1", "1", "0", "true", "-max-pages limit of 3 pages per region reached"},
+		{"PHX", "Instance", "1", "1", "0", "true", "-max-pages limit of 3 pages per region reached"},
+		{"(all)", "(all)", "2", "1", "0", "true", "-max-pages limit of 3 pages per region reached"},
+	}
+	if got := readReport(t, dir, "summary_*.csv"); !reflect.DeepEqual(got, want) {
+		t.Errorf("summary = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,274 @@
+package auditor
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/identity"
+)
+
+// listTagDefaults returns the active tag-default rules defined directly on
+// the given compartment. Rules inherited from parent compartments are not
+// included.
+func listTagDefaults(ctx context.Context, client identity.IdentityClient, compartmentID string) ([]identity.TagDefaultSummary, error) {
+	request := identity.ListTagDefaultsRequest{
+		CompartmentId:  common.String(compartmentID),
+		LifecycleState: identity.TagDefaultSummaryLifecycleStateActive,
+		Limit:          common.Int(1000),
+	}
+
+	var defaults []identity.TagDefaultSummary
+	for {
+		response, err := client.ListTagDefaults(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("ListTagDefaults call failed for %s: %w", compartmentID, err)
+		}
+		defaults = append(defaults, response.Items...)
+
+		if response.OpcNextPage == nil {
+			break
+		}
+		request.Page = response.OpcNextPage
+	}
+	return defaults, nil
+}
+
+func tagDefaultsToString(defaults []identity.TagDefaultSummary) string {
+	var parts []string
+	for _, d := range defaults {
+		parts = append(parts, fmt.Sprintf("%s=%s", getStringValue(d.TagDefinitionName), getStringValue(d.Value)))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// compartmentDefaults is the compartment tree of a tenancy, root included,
+// with the tag defaults defined directly on each compartment.
+type compartmentDefaults struct {
+	compartments []identity.Compartment
+	byID         map[string]identity.Compartment
+	defaults     map[string][]identity.TagDefaultSummary
+	// namespaces maps tag namespace OCIDs to their names.
+	namespaces map[string]string
+	// owner is the -owner-tag, or the CreatedBy key in any namespace.
+	owner tagRef
+}
+
+// loadCompartmentDefaults lists every compartment and its tag defaults,
+// one ListTagDefaults call per compartment, and the tag namespaces the
+// defaults belong to.
+func (cfg *config) loadCompartmentDefaults(ctx context.Context, client identity.IdentityClient, tenancyID string) (*compartmentDefaults, error) {
+	compartments, err := listCompartments(ctx, client, tenancyID)
+	if err != nil {
+		return nil, err
+	}
+	root := identity.Compartment{Id: common.String(tenancyID), Name: common.String("(root)")}
+	compartments = append([]identity.Compartment{root}, compartments...)
+
+	namespaces, err := listTagNamespaces(ctx, client, tenancyID)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &compartmentDefaults{
+		compartments: compartments,
+		byID:         make(map[string]identity.Compartment, len(compartments)),
+		defaults:     make(map[string][]identity.TagDefaultSummary, len(compartments)),
+		namespaces:   make(map[string]string, len(namespaces)),
+		owner:        tagRef{key: ownerTagKey},
+	}
+	if cfg.ownerTag != nil {
+		d.owner = *cfg.ownerTag
+	}
+	for _, ns := range namespaces {
+		d.namespaces[getStringValue(ns.Id)] = getStringValue(ns.Name)
+	}
+	for _, c := range compartments {
+		id := getStringValue(c.Id)
+		d.byID[id] = c
+
+		defaults, err := listTagDefaults(ctx, client, id)
+		if err != nil {
+			return nil, err
+		}
+		d.defaults[id] = defaults
+	}
+	return d, nil
+}
+
+// hasDefault reports whether a compartment itself defines a tag default for
+// a tag. A tag without a namespace matches its key in any namespace.
+func (d *compartmentDefaults) hasDefault(id string, t tagRef) bool {
+	for _, def := range d.defaults[id] {
+		if !strings.EqualFold(getStringValue(def.TagDefinitionName), t.key) {
+			continue
+		}
+		if t.namespace == "" || strings.EqualFold(d.namespaces[getStringValue(def.TagNamespaceId)], t.namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultSource walks up the compartment tree and returns the ID of the
+// closest compartment that defines a tag default for a tag.
+func (d *compartmentDefaults) defaultSource(id string, t tagRef) (string, bool) {
+	for id != "" {
+		c, ok := d.byID[id]
+		if !ok {
+			break
+		}
+		if d.hasDefault(id, t) {
+			return id, true
+		}
+		id = getStringValue(c.CompartmentId)
+	}
+	return "", false
+}
+
+// ownerDefaultSource returns the closest compartment that defines an owner
+// tag default.
+func (d *compartmentDefaults) ownerDefaultSource(id string) (string, bool) {
+	return d.defaultSource(id, d.owner)
+}
+
+// missingDefaults returns the tags that no default of a compartment or its
+// ancestors applies to.
+func (d *compartmentDefaults) missingDefaults(id string, tags []tagRef) []tagRef {
+	var missing []tagRef
+	for _, t := range tags {
+		if _, found := d.defaultSource(id, t); !found {
+			missing = append(missing, t)
+		}
+	}
+	return missing
+}
+
+// ownerDefaultNote explains a resource without an owner by its
+// compartment's tag defaults: either no owner default applies, or one
+// should have tagged the resource and is misconfigured.
+func (d *compartmentDefaults) ownerDefaultNote(compartmentID string) string {
+	if _, ok := d.byID[compartmentID]; !ok {
+		return "unknown compartment"
+	}
+	sourceID, found := d.ownerDefaultSource(compartmentID)
+	switch {
+	case !found:
+		return fmt.Sprintf("genuinely unowned: no %s tag default applies", d.owner.label())
+	case sourceID == compartmentID:
+		return fmt.Sprintf("default misconfigured: %s tag default on this compartment did not apply", d.owner.label())
+	}
+	return fmt.Sprintf("default misconfigured: %s tag default inherited from %s did not apply", d.owner.label(), getStringValue(d.byID[sourceID].Name))
+}
+
+// defaultTags returns the tags every compartment should have a tag default
+// for: the owner tag, then the required tags of every resource type.
+func (d *compartmentDefaults) defaultTags(cfg *config) []tagRef {
+	tags := []tagRef{d.owner}
+	seen := map[string]bool{strings.ToLower(d.owner.label()): true}
+	add := func(refs []tagRef) {
+		for _, t := range refs {
+			if name := strings.ToLower(t.name()); !seen[name] {
+				seen[name] = true
+				tags = append(tags, t)
+			}
+		}
+	}
+	add(cfg.requiredTags)
+	resourceTypes := make([]string, 0, len(cfg.requiredTagsByType))
+	for resourceType := range cfg.requiredTagsByType {
+		resourceTypes = append(resourceTypes, resourceType)
+	}
+	sort.Strings(resourceTypes)
+	for _, resourceType := range resourceTypes {
+		add(cfg.requiredTagsFor(resourceType))
+	}
+	return tags
+}
+
+// AuditTagDefaults writes a compartment-level report of the tag-default rules
+// in the tenancy. Tag defaults are inherited by child compartments, so a
+// compartment is only reported as missing a default for the owner tag or a
+// required tag when neither it nor any of its ancestors define one;
+// resources created there will not be tagged automatically.
+func AuditTagDefaults(ctx context.Context, run *auditRun, configPath string) error {
+	idClient, tenancyID, err := run.cfg.newIdentityClient(configPath, "DEFAULT")
+	if err != nil {
+		return err
+	}
+
+	d, err := run.cfg.loadCompartmentDefaults(ctx, idClient, tenancyID)
+	if err != nil {
+		return err
+	}
+	compartments, byID, defaults := d.compartments, d.byID, d.defaults
+	tags := d.defaultTags(run.cfg)
+
+	file, err := run.createReport(run.outputPath(fmt.Sprintf("tag_defaults_%s.csv", run.timestamp)))
+	if err != nil {
+		return fmt.Errorf("error creating tag defaults report: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	headers := []string{
+		"Compartment ID",
+		"Compartment Name",
+		"Parent Compartment ID",
+		"Tag Defaults",
+		"Owner Default Source",
+		"Missing Tag Defaults",
+		"Status",
+	}
+	if err := writer.Write(headers); err != nil {
+		return fmt.Errorf("error writing tag defaults header: %w", err)
+	}
+
+	var missingCount int
+	for _, c := range compartments {
+		id := getStringValue(c.Id)
+
+		source := ""
+		switch sourceID, found := d.ownerDefaultSource(id); {
+		case !found:
+		case sourceID == id:
+			source = "this compartment"
+		default:
+			source = "inherited from " + getStringValue(byID[sourceID].Name)
+		}
+
+		status, missingNames := "ok", ""
+		if missing := d.missingDefaults(id, tags); len(missing) > 0 {
+			names := make([]string, len(missing))
+			for i, t := range missing {
+				names[i] = t.label()
+			}
+			missingNames = strings.Join(names, ", ")
+			status = "no tag default for " + missingNames + "; resources created here are not tagged automatically"
+			missingCount++
+		}
+
+		row := []string{
+			id,
+			getStringValue(c.Name),
+			getStringValue(c.CompartmentId),
+			tagDefaultsToString(defaults[id]),
+			source,
+			missingNames,
+			status,
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("error writing tag defaults report: %w", err)
+		}
+	}
+
+	slog.Info("Tag defaults audited", "compartments", len(compartments), "without_default", missingCount, "tags", len(tags))
+	return nil
+}
@@ -0,0 +1,71 @@
+package auditor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/identity"
+)
+
+// testDefaults is a tenancy root with a child and a grandchild compartment.
+// The root defaults Oracle-Tags.CreatedBy and the child Ops.CostCenter.
+func testDefaults(owner tagRef) *compartmentDefaults {
+	d := &compartmentDefaults{
+		compartments: []identity.Compartment{
+			{Id: common.String("root"), Name: common.String("(root)")},
+			{Id: common.String("child"), Name: common.String("child"), CompartmentId: common.String("root")},
+			{Id: common.String("grandchild"), Name: common.String("grandchild"), CompartmentId: common.String("child")},
+		},
+		byID: make(map[string]identity.Compartment),
+		defaults: map[string][]identity.TagDefaultSummary{
+			"root":  {{TagNamespaceId: common.String("ns.oracle"), TagDefinitionName: common.String("CreatedBy")}},
+			"child": {{TagNamespaceId: common.String("ns.ops"), TagDefinitionName: common.String("costcenter")}},
+		},
+		namespaces: map[string]string{"ns.oracle": "Oracle-Tags", "ns.ops": "Ops"},
+		owner:      owner,
+	}
+	for _, c := range d.compartments {
+		d.byID[*c.Id] = c
+	}
+	return d
+}
+
+func TestMissingTagDefaults(t *testing.T) {
+	cfg := testConfig(t, "-required-tags", "Ops.CostCenter,Ops.Project")
+	tests := []struct {
+		name        string
+		owner       tagRef
+		compartment string
+		want        []string
+	}{
+		{"root", tagRef{key: ownerTagKey}, "root", []string{"Ops.CostCenter", "Ops.Project"}},
+		{"own default", tagRef{key: ownerTagKey}, "child", []string{"Ops.Project"}},
+		{"inherited defaults", tagRef{key: ownerTagKey}, "grandchild", []string{"Ops.Project"}},
+		{"owner tag in another namespace", tagRef{namespace: "Ops", key: "CreatedBy"}, "grandchild", []string{"Ops.CreatedBy", "Ops.Project"}},
+		{"owner tag in its namespace", tagRef{namespace: "oracle-tags", key: "createdby"}, "grandchild", []string{"Ops.Project"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := testDefaults(tt.owner)
+			var names []string
+			for _, tag := range d.missingDefaults(tt.compartment, d.defaultTags(cfg)) {
+				names = append(names, tag.label())
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("missing defaults = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestOwnerDefaultNote(t *testing.T) {
+	d := testDefaults(tagRef{key: ownerTagKey})
+	if got := d.ownerDefaultNote("grandchild"); got != "default misconfigured: CreatedBy tag default inherited from (root) did not apply" {
+		t.Errorf("note = %q", got)
+	}
+	d = testDefaults(tagRef{namespace: "Ops", key: "Owner"})
+	if got := d.ownerDefaultNote("grandchild"); got != "genuinely unowned: no Ops.Owner tag default applies" {
+		t.Errorf("note = %q", got)
+	}
+}
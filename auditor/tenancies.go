@@ -0,0 +1,153 @@
+package auditor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+)
+
+// profileRef names a profile of one config file; several -config files
+// may use the same profile names.
+type profileRef struct {
+	configPath string
+	profile    string
+}
+
+// tenancyCache maps config profiles to their tenancies. Profiles that
+// share a tenancy share one GetTenancy lookup. It is safe for concurrent
+// use once resolve has returned.
+type tenancyCache struct {
+	cfg       *config
+	mu        sync.Mutex
+	byTenancy map[string]TenancyInfo
+	byProfile map[profileRef]string
+}
+
+func newTenancyCache(cfg *config) *tenancyCache {
+	return &tenancyCache{
+		cfg:       cfg,
+		byTenancy: make(map[string]TenancyInfo),
+		byProfile: make(map[profileRef]string),
+	}
+}
+
+// add records a tenancy already looked up for a profile.
+func (c *tenancyCache) add(configPath, profile string, info TenancyInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.byTenancy[info.TenancyID] = info
+	c.byProfile[profileRef{configPath, profile}] = info.TenancyID
+}
+
+// name returns the name of a looked-up tenancy, or "" if it is unknown.
+func (c *tenancyCache) name(tenancyID string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.byTenancy[tenancyID].TenancyName
+}
+
+// profileTenancyID reads a profile's tenancy OCID from the config file
+// without calling the API.
+func (cfg *config) profileTenancyID(configPath, profile string) (string, error) {
+	provider, err := cfg.newConfigProvider(configPath, profile)
+	if err != nil {
+		return "", fmt.Errorf("failed to create configuration provider: %w", err)
+	}
+	tenancyID, err := provider.TenancyOCID()
+	if err != nil {
+		return "", fmt.Errorf("failed to read tenancy OCID: %w", err)
+	}
+	return tenancyID, nil
+}
+
+// resolve looks up the tenancy of every profile, running at most
+// concurrency GetTenancy calls at a time and skipping tenancies already in
+// the cache. Failures are returned per profile; the other profiles are
+// still resolved.
+func (c *tenancyCache) resolve(ctx context.Context, configPath string, profiles []string, concurrency int) map[string]error {
+	failures := make(map[string]error)
+
+	// Group the profiles by tenancy so each tenancy is looked up once, with
+	// the first of its profiles.
+	pending := make(map[string][]string)
+	for _, profile := range profiles {
+		tenancyID, err := c.cfg.profileTenancyID(configPath, profile)
+		if err != nil {
+			failures[profile] = err
+			continue
+		}
+		c.mu.Lock()
+		_, cached := c.byTenancy[tenancyID]
+		if cached {
+			c.byProfile[profileRef{configPath, profile}] = tenancyID
+		}
+		c.mu.Unlock()
+		if !cached {
+			pending[tenancyID] = append(pending[tenancyID], profile)
+		}
+	}
+
+	var (
+		wg        sync.WaitGroup
+		failureMu sync.Mutex
+		sem       = make(chan struct{}, concurrency)
+	)
+	for tenancyID, sharing := range pending {
+		wg.Add(1)
+		go func(tenancyID string, sharing []string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			info, err := c.cfg.GetHomeRegionKeyFromDefaultConfig(ctx, configPath, sharing[0])
+			if err != nil {
+				failureMu.Lock()
+				for _, profile := range sharing {
+					failures[profile] = fmt.Errorf("tenancy %s: %w", tenancyID, err)
+				}
+				failureMu.Unlock()
+				return
+			}
+			for _, profile := range sharing {
+				c.add(configPath, profile, info)
+			}
+		}(tenancyID, sharing)
+	}
+	wg.Wait()
+	return failures
+}
+
+// homeRegionFor returns the home region of a profile's tenancy, e.g.
+// "us-ashburn-1", or "" when it is unknown.
+func (c *tenancyCache) homeRegionFor(configPath, profile string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tenancyID, ok := c.byProfile[profileRef{configPath, profile}]
+	if !ok {
+		return ""
+	}
+	return string(common.StringToRegion(c.byTenancy[tenancyID].HomeRegionKey))
+}
+
+// count returns the number of distinct tenancies resolved.
+func (c *tenancyCache) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.byTenancy)
+}
+
+// logTenancyFailures logs the profiles whose tenancy could not be resolved.
+func logTenancyFailures(failures map[string]error) {
+	profiles := make([]string, 0, len(failures))
+	for profile := range failures {
+		profiles = append(profiles, profile)
+	}
+	sort.Strings(profiles)
+	for _, profile := range profiles {
+		slog.Warn("Could not look up the tenancy", "profile", profile, "error", failures[profile])
+	}
+}
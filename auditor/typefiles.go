@@ -0,0 +1,124 @@
+package auditor
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// typeFiles writes each resource type of a region to its own report,
+// <section>_<Type>_<timestamp>.csv, created on the type's first resource.
+// Open files take a slot of the run-wide -max-open-type-files bound; when
+// none is free, the region's least recently written file is closed and
+// reopened for appending when it is needed again.
+type typeFiles struct {
+	run     *auditRun
+	section string
+	headers []string
+
+	mu       sync.Mutex
+	open     map[string]*reportFile
+	paths    map[string]string
+	lastUsed map[string]int
+	tick     int
+}
+
+func newTypeFiles(run *auditRun, section string, headers []string) *typeFiles {
+	return &typeFiles{
+		run:      run,
+		section:  section,
+		headers:  headers,
+		open:     make(map[string]*reportFile),
+		paths:    make(map[string]string),
+		lastUsed: make(map[string]int),
+	}
+}
+
+func (t *typeFiles) write(resourceType string, row []string) error {
+	if resourceType == "" {
+		resourceType = "unknown"
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	file, ok := t.open[resourceType]
+	if !ok {
+		t.acquireSlot()
+		var err error
+		if path, created := t.paths[resourceType]; created {
+			file, err = t.run.appendReport(path)
+		} else {
+			path = t.run.reportPath(t.section, fileNameSafe(resourceType))
+			if file, err = t.run.openReport(path, t.headers); err == nil {
+				t.paths[resourceType] = path
+			}
+		}
+		if err != nil {
+			<-t.run.typeFileSlots
+			return err
+		}
+		t.open[resourceType] = file
+	}
+
+	t.tick++
+	t.lastUsed[resourceType] = t.tick
+	return file.Write(row)
+}
+
+// acquireSlot takes a file slot, first closing this region's least recently
+// used file when all slots are taken. The caller holds t.mu.
+func (t *typeFiles) acquireSlot() {
+	select {
+	case t.run.typeFileSlots <- struct{}{}:
+		return
+	default:
+	}
+
+	if len(t.open) > 0 {
+		oldest, oldestTick := "", 0
+		for resourceType := range t.open {
+			if oldest == "" || t.lastUsed[resourceType] < oldestTick {
+				oldest, oldestTick = resourceType, t.lastUsed[resourceType]
+			}
+		}
+		t.closeFile(oldest)
+	}
+	t.run.typeFileSlots <- struct{}{}
+}
+
+// closeFile closes one open file and frees its slot. The caller holds t.mu.
+func (t *typeFiles) closeFile(resourceType string) {
+	if err := t.open[resourceType].Close(); err != nil {
+		slog.Error("Error closing per-type report", "region", t.section, "resource_type", resourceType, "error", err)
+	}
+	delete(t.open, resourceType)
+	<-t.run.typeFileSlots
+}
+
+// Flush writes the buffered rows of every open file of the region.
+func (t *typeFiles) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, file := range t.open {
+		if err := file.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes every open file of the region.
+func (t *typeFiles) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for resourceType := range t.open {
+		t.closeFile(resourceType)
+	}
+}
+
+// count returns the number of per-type files created.
+func (t *typeFiles) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.paths)
+}
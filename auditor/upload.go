@@ -0,0 +1,89 @@
+package auditor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/objectstorage"
+	"github.com/oracle/oci-go-sdk/v65/objectstorage/transfer"
+)
+
+// uploader puts the files of a run into an Object Storage bucket.
+type uploader struct {
+	client    objectstorage.ObjectStorageClient
+	namespace string
+	bucket    string
+	// partSize is both the multipart threshold and the part size, in bytes.
+	partSize int64
+}
+
+// newUploader creates an Object Storage client from the DEFAULT profile.
+// Without -upload-namespace the tenancy's namespace is looked up.
+func (cfg *config) newUploader(ctx context.Context, configPath, namespace, bucket string, partSizeMiB int) (*uploader, error) {
+	provider, err := cfg.newConfigProvider(configPath, "DEFAULT")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create configuration provider: %w", err)
+	}
+	client, err := objectstorage.NewObjectStorageClientWithConfigurationProvider(provider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ObjectStorageClient: %w", err)
+	}
+	cfg.setUserAgent(&client.BaseClient)
+
+	if namespace == "" {
+		response, err := client.GetNamespace(ctx, objectstorage.GetNamespaceRequest{})
+		if err != nil {
+			return nil, fmt.Errorf("GetNamespace call failed: %w", err)
+		}
+		namespace = getStringValue(response.Value)
+	}
+	return &uploader{client: client, namespace: namespace, bucket: bucket, partSize: int64(partSizeMiB) << 20}, nil
+}
+
+// upload puts one file under its object name. Files larger than the part
+// size are sent as a multipart upload.
+func (u *uploader) upload(ctx context.Context, path, objectName string) error {
+	request := transfer.UploadFileRequest{
+		UploadRequest: transfer.UploadRequest{
+			NamespaceName:         common.String(u.namespace),
+			BucketName:            common.String(u.bucket),
+			ObjectName:            common.String(objectName),
+			PartSize:              common.Int64(u.partSize),
+			AllowMultipartUploads: common.Bool(true),
+			ObjectStorageClient:   &u.client,
+		},
+		FilePath: path,
+	}
+	if _, err := transfer.NewUploadManager().UploadFile(ctx, request); err != nil {
+		return fmt.Errorf("error uploading %s: %w", path, err)
+	}
+	return nil
+}
+
+// uploadRun uploads every file the run left in the data directory, under
+// the object names of the run index. A failed file is logged and the
+// others are still uploaded; the number of failures is returned.
+func (run *auditRun) uploadRun(ctx context.Context, u *uploader, manifestPath string) int {
+	paths := append(run.artifacts(), manifestPath)
+	if run.cfg.writeIndex {
+		paths = append(paths, run.outputPath(fmt.Sprintf("index_%s.json", run.timestamp)), run.rootPath("latest.json"))
+	}
+
+	failed := 0
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		objectName := run.objectName(path)
+		if err := u.upload(ctx, path, objectName); err != nil {
+			slog.Warn("Error uploading file", "bucket", u.bucket, "error", err)
+			failed++
+			continue
+		}
+		slog.Info("Uploaded file", "path", path, "bucket", u.bucket, "object", objectName)
+	}
+	return failed
+}
@@ -0,0 +1,173 @@
+package auditor
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// expectedLayouts returns every per-resource column layout a schema version
+// can produce, longest first. Reports written before schema versions were
+// recorded use version 1.
+func (cfg *config) expectedLayouts(version int) ([][]string, bool) {
+	if version == 0 {
+		version = 1
+	}
+	if version < 1 || version > schemaVersion {
+		return nil, false
+	}
+
+	// Each bit of mask turns one layout option on.
+	var layouts [][]string
+	for mask := 0; mask < 1<<8; mask++ {
+		opts := layoutOptions{
+			minimal:      mask&1 != 0,
+			environment:  mask&2 != 0,
+			flatTags:     mask&4 != 0,
+			grace:        mask&8 != 0,
+			statusChange: mask&16 != 0,
+
+			compartmentNames: mask&32 != 0,
+			ownerSource:      mask&64 != 0,
+			namespaces:       mask&128 != 0,
+		}
+		if opts.minimal && (opts.flatTags || opts.namespaces) {
+			continue
+		}
+		layout := columnHeaders(cfg.columnsFor(opts))
+		if version == 1 {
+			layout = legacyLayout(layout)
+		}
+		layouts = append(layouts, layout)
+	}
+	sort.SliceStable(layouts, func(i, j int) bool { return len(layouts[i]) > len(layouts[j]) })
+	return layouts, true
+}
+
+// legacyLayout turns a layout into its schema version 1 form, which had no
+// Resource Region column and called Profile Region.
+func legacyLayout(layout []string) []string {
+	var legacy []string
+	for _, header := range layout {
+		if header == "Resource Region" {
+			continue
+		}
+		if old, ok := legacyHeaders[header]; ok {
+			header = old
+		}
+		legacy = append(legacy, header)
+	}
+	return legacy
+}
+
+// declaredSchemaVersion reads the schema version of a report from the
+// manifest next to it that lists the file.
+func declaredSchemaVersion(path string) (int, string, error) {
+	manifests, err := filepath.Glob(filepath.Join(filepath.Dir(path), "*manifest_*.json"))
+	if err != nil {
+		return 0, "", err
+	}
+	for _, manifestPath := range manifests {
+		bytes, err := os.ReadFile(manifestPath)
+		if err != nil {
+			return 0, "", fmt.Errorf("error reading %s: %w", manifestPath, err)
+		}
+		var manifest Manifest
+		if err := json.Unmarshal(bytes, &manifest); err != nil {
+			return 0, "", fmt.Errorf("error parsing %s: %w", manifestPath, err)
+		}
+		for _, file := range manifest.Files {
+			if filepath.Base(file) == filepath.Base(path) {
+				return manifest.SchemaVersion, manifestPath, nil
+			}
+		}
+	}
+	return 0, "", nil
+}
+
+func commonPrefix(a, b []string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// validateReport checks the header of one per-resource report against the
+// layouts of its declared schema version and prints the result. Columns
+// after a matching layout are report specific, such as Change in a delta
+// report, and are listed but allowed.
+func (cfg *config) validateReport(path string) (bool, error) {
+	file, err := openReportReader(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	header, err := csv.NewReader(file).Read()
+	if err != nil {
+		return false, fmt.Errorf("error reading header: %w", err)
+	}
+
+	version, manifestPath, err := declaredSchemaVersion(path)
+	if err != nil {
+		return false, err
+	}
+	if manifestPath == "" {
+		version = schemaVersion
+		fmt.Printf("%s: no manifest lists this file, assuming schema version %d\n", path, version)
+	} else {
+		fmt.Printf("%s: schema version %d declared by %s\n", path, version, manifestPath)
+	}
+
+	layouts, ok := cfg.expectedLayouts(version)
+	if !ok {
+		fmt.Printf("FAIL %s: schema version %d is not known to this build (version %d)\n", path, version, schemaVersion)
+		return false, nil
+	}
+
+	best := layouts[0]
+	for _, layout := range layouts {
+		n := commonPrefix(header, layout)
+		if n == len(layout) {
+			fmt.Printf("PASS %s\n  columns: %s\n", path, strings.Join(layout, ", "))
+			if extra := header[n:]; len(extra) > 0 {
+				fmt.Printf("  report-specific columns: %s\n", strings.Join(extra, ", "))
+			}
+			return true, nil
+		}
+		if n > commonPrefix(header, best) {
+			best = layout
+		}
+	}
+
+	n := commonPrefix(header, best)
+	fmt.Printf("FAIL %s: column %d does not match\n", path, n+1)
+	fmt.Printf("  detected: %s\n  expected: %s\n", strings.Join(header, ", "), strings.Join(best, ", "))
+	return false, nil
+}
+
+// runValidate implements the validate subcommand and returns the exit
+// code: 0 when every file passes.
+func (cfg *config) runValidate(paths []string) int {
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: oci-tag-auditor validate REPORT.csv...")
+		return 2
+	}
+
+	code := 0
+	for _, path := range paths {
+		passed, err := cfg.validateReport(path)
+		if err != nil {
+			fmt.Printf("FAIL %s: %v\n", path, err)
+		}
+		if !passed {
+			code = 1
+		}
+	}
+	return code
+}
@@ -0,0 +1,27 @@
+package auditor
+
+import (
+	"strings"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+)
+
+// Version is the tool version sent in the user agent. The command sets it
+// from its own version, stamped at build time with
+// -ldflags "-X main.version=1.2.3".
+var Version = "dev"
+
+// userAgent identifies this tool's API calls in the OCI audit logs, e.g.
+// "oci-tag-auditor/1.2.3 nightly-job".
+func (cfg *config) userAgent() string {
+	ua := "oci-tag-auditor/" + Version
+	if suffix := strings.TrimSpace(cfg.userAgentSuffix); suffix != "" {
+		ua += " " + suffix
+	}
+	return ua
+}
+
+// setUserAgent appends the tool's user agent to the SDK default of a client.
+func (cfg *config) setUserAgent(client *common.BaseClient) {
+	client.UserAgent = client.UserAgent + " " + cfg.userAgent()
+}
@@ -0,0 +1,90 @@
+package auditor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// webhookTimeout bounds the -webhook-url request.
+const webhookTimeout = 30 * time.Second
+
+// webhookPayload is the -webhook-url message. Slack shows text and ignores
+// the other fields, which other receivers can read instead.
+type webhookPayload struct {
+	Text           string         `json:"text"`
+	Timestamp      string         `json:"timestamp"`
+	TotalResources int            `json:"total_resources"`
+	MissingTags    int            `json:"missing_tags"`
+	NoOwner        int            `json:"no_owner"`
+	Regions        []RegionCounts `json:"regions"`
+	FailedRegions  []string       `json:"failed_regions,omitempty"`
+}
+
+// webhookPayload builds the run's webhook message, regions in name order.
+func (s *runSummary) webhookPayload(run *auditRun) webhookPayload {
+	payload := webhookPayload{Timestamp: run.manifest.StartedAt.UTC().Format(time.RFC3339), Regions: s.regionCounts()}
+	lines := []string{""}
+	for _, c := range payload.Regions {
+		payload.TotalResources += c.Resources
+		payload.MissingTags += c.MissingTags
+		payload.NoOwner += c.NoOwner
+		if c.MissingTags > 0 || c.NoOwner > 0 {
+			lines = append(lines, fmt.Sprintf("• %s: %d missing tags, %d no owner", c.Region, c.MissingTags, c.NoOwner))
+		}
+	}
+	payload.FailedRegions, _ = run.manifest.regionFailures()
+	if len(payload.FailedRegions) > 0 {
+		lines = append(lines, "Failed regions: "+strings.Join(payload.FailedRegions, ", "))
+	}
+	lines[0] = fmt.Sprintf("OCI tag audit %s: %d resources scanned, %d with missing tags, %d with no owner",
+		payload.Timestamp, payload.TotalResources, payload.MissingTags, payload.NoOwner)
+	payload.Text = strings.Join(lines, "\n")
+	return payload
+}
+
+// sendWebhook posts the run summary to url. Unless always is set it is only
+// sent when some resource has missing tags or no owner. Errors are returned
+// for logging; they do not fail the run.
+func (s *runSummary) sendWebhook(ctx context.Context, run *auditRun, url string, always bool) error {
+	payload := s.webhookPayload(run)
+	if !always && payload.MissingTags == 0 && payload.NoOwner == 0 {
+		slog.Info("No violations, webhook not sent")
+		return nil
+	}
+	if run.cfg.dryRun {
+		slog.Info("Dry run: would send webhook", "missing_tags", payload.MissingTags, "no_owner", payload.NoOwner)
+		return nil
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding webhook payload: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating webhook request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", run.cfg.userAgent())
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("error sending webhook: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", response.Status, strings.TrimSpace(string(detail)))
+	}
+	slog.Info("Sent webhook", "status", response.StatusCode)
+	return nil
+}
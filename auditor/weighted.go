@@ -0,0 +1,163 @@
+package auditor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultWeight is the weight of resources without the -weight-tag tag, or
+// with a value -weights does not list.
+const defaultWeight = 1.0
+
+// parseWeights parses a comma-separated list of value=weight pairs, e.g.
+// "prod=3,staging=2". Values are matched case-insensitively.
+func parseWeights(list string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for _, item := range splitList(list) {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid weight %q, expected value=weight", item)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight %q, expected a non-negative number", item)
+		}
+		weights[strings.ToLower(strings.TrimSpace(parts[0]))] = weight
+	}
+	return weights, nil
+}
+
+// complianceScore tallies the tenancy-wide compliance, by resource count
+// and weighted by the -weight-tag value of each resource. It is fed by an
+// OnResource hook and is safe for concurrent use.
+type complianceScore struct {
+	cfg     *config
+	tag     tagRef
+	weights map[string]float64
+
+	mu      sync.Mutex
+	tallies map[string]*scoreTally
+	total   scoreTally
+}
+
+type scoreTally struct {
+	// weight is the weight of the resources in this tally; it is unused for
+	// the total.
+	weight       float64
+	resources    int
+	nonCompliant int
+	// weighted and weightedNonCompliant sum the weights.
+	weighted             float64
+	weightedNonCompliant float64
+}
+
+func newComplianceScore(cfg *config, tag tagRef, weights map[string]float64) *complianceScore {
+	return &complianceScore{cfg: cfg, tag: tag, weights: weights, tallies: make(map[string]*scoreTally)}
+}
+
+// weightOf returns the weight-tag value of a resource, lowercased, and its
+// weight.
+func (s *complianceScore) weightOf(r ResourceSummary) (string, float64) {
+	value, ok := definedTagValue(r.DefinedTags, s.tag.namespace, s.tag.key)
+	value = strings.ToLower(strings.TrimSpace(value))
+	if !ok || value == "" {
+		return "(none)", defaultWeight
+	}
+	if weight, ok := s.weights[value]; ok {
+		return value, weight
+	}
+	return value, defaultWeight
+}
+
+// onResource counts a checked resource. In-flight resources and resources
+// in their grace period are not checked and are left out of the score.
+func (s *complianceScore) onResource(_ string, r ResourceSummary) {
+	if s.cfg.exemptFromChecks(r) {
+		return
+	}
+	value, weight := s.weightOf(r)
+	nonCompliant := len(s.cfg.complianceReasons(r)) > 0
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tallies[value]
+	if !ok {
+		t = &scoreTally{weight: weight}
+		s.tallies[value] = t
+	}
+	for _, t := range []*scoreTally{t, &s.total} {
+		t.resources++
+		t.weighted += weight
+		if nonCompliant {
+			t.nonCompliant++
+			t.weightedNonCompliant += weight
+		}
+	}
+}
+
+// percentages returns the unweighted and weighted compliance of a tally.
+func (t *scoreTally) percentages() (string, string) {
+	unweighted, weighted := "N/A", "N/A"
+	if t.resources > 0 {
+		unweighted = fmt.Sprintf("%.1f", float64(t.resources-t.nonCompliant)*100/float64(t.resources))
+	}
+	if t.weighted > 0 {
+		weighted = fmt.Sprintf("%.1f", (t.weighted-t.weightedNonCompliant)*100/t.weighted)
+	}
+	return unweighted, weighted
+}
+
+// Write writes the compliance of each weight-tag value, heaviest first,
+// and the tenancy-wide score, and logs the score.
+func (s *complianceScore) Write(run *auditRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := run.createReport(run.outputPath(fmt.Sprintf("compliance_score_%s.csv", run.timestamp)))
+	if err != nil {
+		return fmt.Errorf("error creating compliance score report: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	headers := []string{s.tag.name(), "Weight", "Resources", "Non-Compliant", "Compliant (%)", "Weighted Compliant (%)"}
+	if err := writer.Write(headers); err != nil {
+		return fmt.Errorf("error writing compliance score header: %w", err)
+	}
+
+	values := make([]string, 0, len(s.tallies))
+	for value := range s.tallies {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		a, b := s.tallies[values[i]], s.tallies[values[j]]
+		if a.weight != b.weight {
+			return a.weight > b.weight
+		}
+		return values[i] < values[j]
+	})
+
+	for _, value := range values {
+		t := s.tallies[value]
+		unweighted, weighted := t.percentages()
+		row := []string{value, strconv.FormatFloat(t.weight, 'g', -1, 64), fmt.Sprintf("%d", t.resources), fmt.Sprintf("%d", t.nonCompliant), unweighted, weighted}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("error writing compliance score report: %w", err)
+		}
+	}
+
+	unweighted, weighted := s.total.percentages()
+	total := []string{"(all)", "", fmt.Sprintf("%d", s.total.resources), fmt.Sprintf("%d", s.total.nonCompliant), unweighted, weighted}
+	if err := writer.Write(total); err != nil {
+		return fmt.Errorf("error writing compliance score report: %w", err)
+	}
+	slog.Info("Compliance score", "compliant_percent", unweighted, "resources", s.total.resources, "weighted_percent", weighted, "weight_tag", s.tag.name())
+	return nil
+}
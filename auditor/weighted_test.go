@@ -0,0 +1,73 @@
+package auditor
+
+import "testing"
+
+func TestComplianceScoreWeighted(t *testing.T) {
+	cfg := testConfig(t, "-required-tags", "Ops.CostCenter")
+	weights, err := parseWeights("prod=3, staging=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	score := newComplianceScore(cfg, tagRef{namespace: "Ops", key: "Env"}, weights)
+
+	for _, tags := range []string{
+		`{"Oracle-Tags":{"CreatedBy":"alice"},"Ops":{"Env":"prod","CostCenter":"42"}}`,
+		`{"Oracle-Tags":{"CreatedBy":"alice"},"Ops":{"Env":"PROD"}}`,
+		`{"Oracle-Tags":{"CreatedBy":"alice"},"Ops":{"Env":"dev","CostCenter":"42"}}`,
+		`{"Oracle-Tags":{"CreatedBy":"alice"},"Ops":{"CostCenter":"42"}}`,
+	} {
+		score.onResource("DEFAULT", testResource("ocid1.instance.a", tags))
+	}
+
+	tests := []struct {
+		value                string
+		weight               float64
+		resources            int
+		nonCompliant         int
+		unweighted, weighted string
+	}{
+		{"prod", 3, 2, 1, "50.0", "50.0"},
+		{"dev", 1, 1, 0, "100.0", "100.0"},
+		{"(none)", 1, 1, 0, "100.0", "100.0"},
+	}
+	for _, tt := range tests {
+		tally, ok := score.tallies[tt.value]
+		if !ok {
+			t.Errorf("no tally for %s", tt.value)
+			continue
+		}
+		unweighted, weighted := tally.percentages()
+		if tally.weight != tt.weight || tally.resources != tt.resources || tally.nonCompliant != tt.nonCompliant || unweighted != tt.unweighted || weighted != tt.weighted {
+			t.Errorf("%s: weight %v, %d resources, %d non-compliant, %s%%, weighted %s%%", tt.value, tally.weight, tally.resources, tally.nonCompliant, unweighted, weighted)
+		}
+	}
+
+	// Three of four resources comply, but the non-compliant one weighs 3
+	// of the total weight of 8.
+	unweighted, weighted := score.total.percentages()
+	if score.total.weighted != 8 || unweighted != "75.0" || weighted != "62.5" {
+		t.Errorf("total: weight %v, %s%%, weighted %s%%, want 8, 75.0%%, 62.5%%", score.total.weighted, unweighted, weighted)
+	}
+}
+
+func TestComplianceScoreEmpty(t *testing.T) {
+	var tally scoreTally
+	if unweighted, weighted := tally.percentages(); unweighted != "N/A" || weighted != "N/A" {
+		t.Errorf("empty tally: %s, %s", unweighted, weighted)
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	weights, err := parseWeights("Prod=3,dev=0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weights["prod"] != 3 || weights["dev"] != 0.5 {
+		t.Errorf("weights = %v", weights)
+	}
+	for _, list := range []string{"prod", "=3", "prod=x", "prod=-1"} {
+		if _, err := parseWeights(list); err == nil {
+			t.Errorf("parseWeights(%q) accepted an invalid weight", list)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package auditor
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// formatXLSX writes the main, missing tags and no owner reports of every
+// region as sheets of one workbook, audit_<timestamp>.xlsx.
+const formatXLSX = "xlsx"
+
+// xlsxMaxSheetName is Excel's limit on sheet name length.
+const xlsxMaxSheetName = 31
+
+// summarySheet is the name of the run summary sheet, always the first.
+const summarySheet = "Summary"
+
+// xlsxWorkbook collects the sheets of the -format xlsx workbook. Each
+// sheet's rows are streamed to a temporary file as they are written, so
+// memory stays flat, and the workbook is assembled from them once every
+// region has finished. Sheets may be added concurrently.
+type xlsxWorkbook struct {
+	mu     sync.Mutex
+	sheets []*xlsxSheet
+	names  map[string]bool
+}
+
+// xlsxSheet is one sheet. Its rows, header included, are written by one
+// goroutine.
+type xlsxSheet struct {
+	name   string
+	file   *os.File
+	out    *bufio.Writer
+	rows   int
+	cols   int
+	closed bool
+}
+
+// newXLSXWorkbook returns an empty workbook. The summary sheet's name is
+// reserved, so a region of that name gets a suffix instead.
+func newXLSXWorkbook() *xlsxWorkbook {
+	return &xlsxWorkbook{names: map[string]bool{strings.ToLower(summarySheet): true}}
+}
+
+// sheetName makes a valid, unique sheet name: characters Excel rejects are
+// replaced, long names are cut and a clash gets a numeric suffix. The
+// caller holds w.mu.
+func (w *xlsxWorkbook) sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, "'")
+	if name == "" {
+		name = "Sheet"
+	}
+
+	candidate := truncateRunes(name, xlsxMaxSheetName)
+	for i := 2; w.names[strings.ToLower(candidate)]; i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		candidate = truncateRunes(name, xlsxMaxSheetName-len(suffix)) + suffix
+	}
+	w.names[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+// addSheet starts a sheet and writes its header row.
+func (w *xlsxWorkbook) addSheet(name string, headers []string) (*xlsxSheet, error) {
+	return w.startSheet(name, false, headers)
+}
+
+// addSummarySheet starts the summary sheet.
+func (w *xlsxWorkbook) addSummarySheet(headers []string) (*xlsxSheet, error) {
+	return w.startSheet(summarySheet, true, headers)
+}
+
+func (w *xlsxWorkbook) startSheet(name string, reserved bool, headers []string) (*xlsxSheet, error) {
+	file, err := os.CreateTemp("", "oci-tag-auditor-sheet-*.xml")
+	if err != nil {
+		return nil, fmt.Errorf("error creating sheet %s: %w", name, err)
+	}
+
+	w.mu.Lock()
+	if !reserved {
+		name = w.sheetName(name)
+	}
+	sheet := &xlsxSheet{name: name, file: file, out: bufio.NewWriter(file), cols: len(headers)}
+	w.sheets = append(w.sheets, sheet)
+	w.mu.Unlock()
+
+	if err := sheet.writeRow(headers, true); err != nil {
+		return nil, err
+	}
+	return sheet, nil
+}
+
+// writeRow appends a row. Cells holding a plain integer are written as
+// numbers so they sort and sum; everything else, including values with
+// leading zeros, stays text.
+func (s *xlsxSheet) writeRow(row []string, header bool) error {
+	s.rows++
+	fmt.Fprintf(s.out, `<row r="%d">`, s.rows)
+	for i, value := range row {
+		ref := xlsxColumn(i) + strconv.Itoa(s.rows)
+		switch {
+		case header:
+			fmt.Fprintf(s.out, `<c r="%s" t="inlineStr" s="1"><is><t xml:space="preserve">`, ref)
+		case isXLSXNumber(value):
+			fmt.Fprintf(s.out, `<c r="%s"><v>%s</v></c>`, ref, value)
+			continue
+		default:
+			fmt.Fprintf(s.out, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+		}
+		if err := xml.EscapeText(s.out, []byte(value)); err != nil {
+			return err
+		}
+		s.out.WriteString(`</t></is></c>`)
+	}
+	if len(row) > s.cols {
+		s.cols = len(row)
+	}
+	_, err := s.out.WriteString("</row>")
+	return err
+}
+
+func isXLSXNumber(value string) bool {
+	if value == "" || len(value) > 15 || (len(value) > 1 && value[0] == '0') {
+		return false
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// xlsxColumn returns the column letters of a zero-based column index.
+func xlsxColumn(i int) string {
+	var letters []byte
+	for i++; i > 0; i = (i - 1) / 26 {
+		letters = append([]byte{byte('A' + (i-1)%26)}, letters...)
+	}
+	return string(letters)
+}
+
+func (s *xlsxSheet) Flush() error {
+	return s.out.Flush()
+}
+
+// Close flushes the sheet's rows. The temporary file is kept until the
+// workbook is written.
+func (s *xlsxSheet) Close() error {
+	s.closed = true
+	return s.out.Flush()
+}
+
+// ref is the cell range of the sheet, for the auto filter; with absolute
+// set it is written as $A$1:$K$9 for the filter's defined name.
+func (s *xlsxSheet) ref(absolute bool) string {
+	cols := s.cols
+	if cols == 0 {
+		cols = 1
+	}
+	if absolute {
+		return fmt.Sprintf("$A$1:$%s$%d", xlsxColumn(cols-1), s.rows)
+	}
+	return fmt.Sprintf("A1:%s%d", xlsxColumn(cols-1), s.rows)
+}
+
+// Write assembles audit_<timestamp>.xlsx from the sheets, the summary sheet
+// first and then the others by name, and removes the temporary files. Every
+// sheet must be closed.
+func (w *xlsxWorkbook) Write(run *auditRun) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	defer func() {
+		for _, s := range w.sheets {
+			s.file.Close()
+			os.Remove(s.file.Name())
+		}
+	}()
+
+	sheets := append([]*xlsxSheet{}, w.sheets...)
+	sort.SliceStable(sheets, func(i, j int) bool {
+		if (sheets[i].name == summarySheet) != (sheets[j].name == summarySheet) {
+			return sheets[i].name == summarySheet
+		}
+		return sheets[i].name < sheets[j].name
+	})
+
+	file, err := run.createFile(run.outputPath(fmt.Sprintf("audit_%s.xlsx", run.timestamp)), false)
+	if err != nil {
+		return fmt.Errorf("error creating workbook: %w", err)
+	}
+	defer file.Close()
+
+	zipWriter := zip.NewWriter(file)
+	if err := writeXLSXParts(zipWriter, sheets); err != nil {
+		zipWriter.Close()
+		return fmt.Errorf("error writing workbook: %w", err)
+	}
+	// Both closes write data: the zip central directory and the file itself.
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("error writing workbook: %w", err)
+	}
+	return file.Close()
+}
+
+const xlsxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+// The fixed parts of a minimal SpreadsheetML package. Style 1 is the bold
+// header row.
+const (
+	xlsxMainNS = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	xlsxRelNS  = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+
+	xlsxRootRels = xlsxHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="` + xlsxRelNS + `/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+
+	xlsxStyles = xlsxHeader + `<styleSheet xmlns="` + xlsxMainNS + `">` +
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+		`</styleSheet>`
+)
+
+func writeXLSXParts(zipWriter *zip.Writer, sheets []*xlsxSheet) error {
+	var contentTypes, workbook, workbookRels, definedNames strings.Builder
+
+	contentTypes.WriteString(xlsxHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	workbook.WriteString(xlsxHeader + `<workbook xmlns="` + xlsxMainNS + `" xmlns:r="` + xlsxRelNS + `"><sheets>`)
+	workbookRels.WriteString(xlsxHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+
+	for i, s := range sheets {
+		n := i + 1
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlAttr(s.name), n, n)
+		fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="%s/worksheet" Target="worksheets/sheet%d.xml"/>`, n, xlsxRelNS, n)
+		fmt.Fprintf(&definedNames, `<definedName name="_xlnm._FilterDatabase" localSheetId="%d" hidden="1">%s</definedName>`,
+			i, xmlAttr("'"+strings.ReplaceAll(s.name, "'", "''")+"'!"+s.ref(true)))
+	}
+
+	contentTypes.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets><definedNames>` + definedNames.String() + `</definedNames></workbook>`)
+	fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="%s/styles" Target="styles.xml"/></Relationships>`, len(sheets)+1, xlsxRelNS)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", contentTypes.String()},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", workbookRels.String()},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, part := range parts {
+		writer, err := zipWriter.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(writer, part.content); err != nil {
+			return err
+		}
+	}
+
+	for i, s := range sheets {
+		if !s.closed {
+			return fmt.Errorf("sheet %s is still open", s.name)
+		}
+		writer, err := zipWriter.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1))
+		if err != nil {
+			return err
+		}
+		if err := s.copyTo(writer); err != nil {
+			return fmt.Errorf("sheet %s: %w", s.name, err)
+		}
+	}
+	return nil
+}
+
+// copyTo writes the sheet's part: the frozen header row, the rows from the
+// temporary file and the auto filter over all of them.
+func (s *xlsxSheet) copyTo(w io.Writer) error {
+	if _, err := io.WriteString(w, xlsxHeader+`<worksheet xmlns="`+xlsxMainNS+`">`+
+		`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`+
+		`<sheetData>`); err != nil {
+		return err
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, s.file); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, `</sheetData><autoFilter ref="%s"/></worksheet>`, s.ref(false))
+	return err
+}
+
+// xmlAttr escapes text for an attribute or element value.
+func xmlAttr(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}